@@ -120,6 +120,63 @@ func BenchmarkApplyPatchMultipleOps(b *testing.B) {
 	}
 }
 
+func BenchmarkApplyCompiledPatchMultipleOps(b *testing.B) {
+	doc := []byte(`{"a":1,"b":2,"c":3,"d":4,"e":5}`)
+	patchJSON := []byte(`[
+		{"op":"replace","path":"/a","value":10},
+		{"op":"replace","path":"/b","value":20},
+		{"op":"add","path":"/f","value":6},
+		{"op":"remove","path":"/c"}
+	]`)
+	patch, _ := DecodePatch(patchJSON)
+	compiled, _ := patch.Compile()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compiled.Apply(doc)
+	}
+}
+
+func BenchmarkApplyPatchManyDocuments(b *testing.B) {
+	docs, patch := manyDocuments()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		patch.ApplyIndent(docs[i%len(docs)], "  ")
+	}
+}
+
+func BenchmarkApplyCompiledPatchManyDocuments(b *testing.B) {
+	docs, patch := manyDocuments()
+	compiled, _ := patch.Compile()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compiled.ApplyIndent(docs[i%len(docs)], "  ")
+	}
+}
+
+// manyDocuments returns documents along with a migration patch applying to
+// all of them.
+func manyDocuments() ([][]byte, Patch) {
+	docs := make([][]byte, 100)
+	for i := range docs {
+		docs[i] = []byte(fmt.Sprintf(`{"id":%d,"profile":{"contact":{"emails":["a%d@b.c"],"phone":"%d"}},"settings":{"theme":"dark"}}`, i, i, i))
+	}
+
+	patch, _ := DecodePatch([]byte(`[
+		{"op":"test","path":"/settings/theme","value":"dark"},
+		{"op":"add","path":"/profile/contact/phones","value":{}},
+		{"op":"move","from":"/profile/contact/phone","path":"/profile/contact/phones/mobile"},
+		{"op":"replace","path":"/settings/theme","value":{"name":"dark","contrast":[1,2,3]}},
+		{"op":"copy","from":"/settings/theme","path":"/profile/theme"},
+		{"op":"add","path":"/profile/contact/emails/-","value":"support@b.c"},
+		{"op":"add","path":"/version","value":2}
+	]`))
+
+	return docs, patch
+}
+
 func BenchmarkApplyPatchArray(b *testing.B) {
 	doc := []byte(`{"items":[1,2,3,4,5]}`)
 	patchJSON := []byte(`[
@@ -135,6 +192,28 @@ func BenchmarkApplyPatchArray(b *testing.B) {
 	}
 }
 
+func BenchmarkApplyPatchLargeArray(b *testing.B) {
+	items := make([]int, 10000)
+	for i := range items {
+		items[i] = i
+	}
+	doc, _ := json.Marshal(map[string]interface{}{"items": items})
+
+	ops := make([]Operation, 0, 1000)
+	for i := 0; i < 500; i++ {
+		ops = append(ops,
+			NewPatch("add", fmt.Sprintf("/items/%d", i*17), -i),
+			NewPatch("remove", fmt.Sprintf("/items/%d", 9999-i*13), nil),
+		)
+	}
+	patch := Patch(ops)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		patch.Apply(doc)
+	}
+}
+
 func BenchmarkApplyPatchNested(b *testing.B) {
 	doc := []byte(`{"a":{"b":{"c":{"d":1}}}}`)
 	patchJSON := []byte(`[{"op":"replace","path":"/a/b/c/d","value":2}]`)
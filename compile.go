@@ -0,0 +1,138 @@
+package jsonpatch
+
+import (
+	"fmt"
+
+	"github.com/goccy/go-json"
+)
+
+// CompiledPatch is a patch prepared to be applied to many documents: the
+// kinds, pointers and values of its operations are decoded and validated once
+// by Compile. It is safe for concurrent use.
+type CompiledPatch struct {
+	ops []compiledOp
+}
+
+// compiledOp is an operation with its locations parsed and its value
+// marshaled, nil if it has none, along with the predicate of the extension
+// operations.
+type compiledOp struct {
+	Operation
+	path  Pointer
+	from  Pointer
+	value json.RawMessage
+	pred  *predicate
+}
+
+// compileOp prepares op to be applied, only its kind, locations and value are
+// checked.
+func compileOp(op Operation) (compiledOp, error) {
+	c := compiledOp{Operation: op}
+
+	if err := op.checkMembers(); err != nil {
+		return c, err
+	}
+
+	switch op.Op {
+	case "add", "remove", "replace", "move", "copy", "test":
+	default:
+		if !isPredicate(op.Op) {
+			return c, nil
+		}
+	}
+
+	if op.Not && op.Op != "test" && !isPredicate(op.Op) {
+		return c, fmt.Errorf("%w: %s operations cannot be negated", ErrInvalidPredicate, op.Op)
+	}
+
+	var err error
+	if c.path, err = ParsePointer(op.Path); err != nil {
+		return c, err
+	}
+
+	if op.Op == "move" || op.Op == "copy" {
+		if c.from, err = ParsePointer(op.From); err != nil {
+			return c, err
+		}
+	}
+
+	if c.value, err = op.rawValue(); err != nil {
+		return c, err
+	}
+
+	if isPredicate(op.Op) {
+		if c.pred, err = parsePredicate(op.Op, c.value); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
+
+// node returns a new node holding the value of the operation, so that the
+// documents the operation applies to do not share it.
+func (o *compiledOp) node() *lazyNode {
+	return newValueNode(o.value)
+}
+
+// Compile validates p and prepares it to be applied to many documents. An
+// operation that can never apply is reported as a *PatchError, as done by
+// DecodePatch, which accepts the extension operations as well.
+func (p Patch) Compile() (*CompiledPatch, error) {
+	c := &CompiledPatch{ops: make([]compiledOp, len(p))}
+
+	for i, op := range p {
+		err := op.validateExtended()
+
+		if err == nil {
+			c.ops[i], err = compileOp(op)
+		}
+
+		if err != nil {
+			return nil, newPatchError(i, op, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Apply mutates a JSON document according to the patch, and returns the new
+// document.
+func (c *CompiledPatch) Apply(doc []byte) ([]byte, error) {
+	return c.ApplyWithOptions(doc, NewApplyOptions())
+}
+
+// ApplyIndent mutates a JSON document according to the patch, and returns the
+// new document indented.
+func (c *CompiledPatch) ApplyIndent(doc []byte, indent string) ([]byte, error) {
+	options := NewApplyOptions()
+	options.Indent = indent
+	return c.ApplyWithOptions(doc, options)
+}
+
+// ApplyWithOptions mutates a JSON document according to the patch and the
+// passed in ApplyOptions, and returns the new document. A nil options uses
+// the defaults from NewApplyOptions.
+func (c *CompiledPatch) ApplyWithOptions(doc []byte, options *ApplyOptions) ([]byte, error) {
+	if options == nil {
+		options = NewApplyOptions()
+	}
+
+	if err := options.Limits.checkOperations(len(c.ops)); err != nil {
+		return nil, err
+	}
+
+	a, err := newApplier(doc, options, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range c.ops {
+		if err := a.apply(&c.ops[i]); err != nil {
+			return nil, newPatchError(i, c.ops[i].Operation, err)
+		}
+	}
+
+	return a.result()
+}
@@ -0,0 +1,92 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	for _, c := range Cases {
+		patch, err := DecodePatch([]byte(c.patch))
+		require.NoError(t, err)
+		compiled, err := patch.Compile()
+		require.NoError(t, err)
+
+		expected, err := patch.Apply([]byte(c.doc))
+		require.NoError(t, err)
+		out, err := compiled.Apply([]byte(c.doc))
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(out), c.patch)
+	}
+
+	for _, c := range BadCases {
+		patch, err := DecodePatch([]byte(c.patch))
+		if err != nil {
+			continue
+		}
+		compiled, err := patch.Compile()
+		require.NoError(t, err)
+		_, expected := patch.Apply([]byte(c.doc))
+		_, err = compiled.Apply([]byte(c.doc))
+		assert.Equal(t, expected, err, c.patch)
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	testCases := []struct {
+		patch Patch
+		index int
+		cause error
+	}{
+		{Patch{NewPatch("add", "/a", 1), NewPatch("frobnicate", "/a", nil)}, 1, ErrUnknownOp},
+		{Patch{NewPatch("remove", "a", nil)}, 0, ErrInvalidPointer},
+		{Patch{{Op: "copy", From: "a", Path: "/b"}}, 0, ErrInvalidPointer},
+		{Patch{NewPatch("test", "/a", nil)}, 0, ErrMissingValue},
+	}
+
+	for _, tc := range testCases {
+		_, err := tc.patch.Compile()
+		var perr *PatchError
+		require.True(t, errors.As(err, &perr))
+		assert.Equal(t, tc.index, perr.Index)
+		assert.True(t, errors.Is(err, tc.cause), "%v", err)
+	}
+
+	_, err := Patch{NewPatch("add", "/a", make(chan int))}.Compile()
+	assert.Error(t, err)
+}
+
+func TestCompileConcurrent(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[
+		{"op":"add","path":"/items/-","value":{"tags":[]}},
+		{"op":"add","path":"/items/1/tags/-","value":"new"},
+		{"op":"copy","from":"/items/1","path":"/copy"},
+		{"op":"move","from":"/name","path":"/copy/name"},
+		{"op":"test","path":"/copy","value":{"tags":["new"],"name":"n"}}
+	]`))
+	require.NoError(t, err)
+	compiled, err := patch.Compile()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				doc := fmt.Sprintf(`{"name":"n","items":[%d]}`, i*100+j)
+				out, err := compiled.ApplyIndent([]byte(doc), "  ")
+				if assert.NoError(t, err) {
+					expected := fmt.Sprintf(`{"items":[%d,{"tags":["new"]}],"copy":{"tags":["new"],"name":"n"}}`, i*100+j)
+					assert.True(t, Equal([]byte(expected), out), "got %s", out)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,259 @@
+package jsonpatch
+
+import (
+	"strconv"
+
+	"github.com/goccy/go-json"
+)
+
+// Compose merges the successive patches into a single patch, equivalent on
+// any document the sequence applies to. It works without the document, so
+// operations are only merged when that is sound whatever the document is:
+//
+//   - a "replace" following an "add" or a "replace" of the same location
+//     takes its place,
+//   - an "add" followed by a "remove" of a location vacated earlier in the
+//     sequence cancel out,
+//   - operations within a value set by an "add" or a "replace" are applied
+//     to that value,
+//   - writes within a location are dropped when it is then replaced or
+//     removed as a whole,
+//   - a "test" of the value set by an "add" or a "replace", or already
+//     tested, is dropped.
+//
+// Tokens made of digits, or "-", are assumed to be array indices: operations
+// on different indices of the same container are never reordered. The ones
+// that cannot be merged are kept in order, ComposeOn gives a smaller patch
+// for a known document.
+func Compose(patches ...Patch) (Patch, error) {
+	c := composer{ops: Patch{}}
+
+	i := 0
+	for _, patch := range patches {
+		for _, op := range patch {
+			if err := op.validate(); err != nil {
+				return nil, newPatchError(i, op, err)
+			}
+			c.push(op)
+			i++
+		}
+	}
+
+	return c.ops, nil
+}
+
+// ComposeOn merges the successive patches into a single patch, equivalent to
+// the sequence on doc only: the patches are applied to doc and the result is
+// diffed against it.
+func ComposeOn(doc []byte, patches ...Patch) (Patch, error) {
+	out := doc
+
+	for _, patch := range patches {
+		var err error
+		out, err = patch.Apply(out)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return diffPatch(doc, out)
+}
+
+// diffPatch returns the patch turning the valid document a into b, which
+// replaces the whole document when they are of different types.
+func diffPatch(a, b []byte) (Patch, error) {
+	ops, err := CreatePatch(a, b)
+	if err != nil {
+		raw := json.RawMessage(b)
+		return Patch{newOperation("replace", "", "", newLazyNode(&raw))}, nil
+	}
+
+	return ops, nil
+}
+
+// composer holds the operations composed so far.
+type composer struct {
+	ops Patch
+}
+
+// push composes op with the operations so far, looking for one to merge it
+// with among the ones it does not commute with.
+func (c *composer) push(op Operation) {
+	kind := op.kind()
+	path := mustPointer(op.path())
+
+	for i := len(c.ops) - 1; i >= 0; i-- {
+		prev := c.ops[i]
+
+		if independentOps(prev, op) {
+			continue
+		}
+
+		prevKind := prev.kind()
+		prevPath := mustPointer(prev.path())
+
+		if (kind == "replace" || kind == "remove") && isWrite(prevKind) && len(prevPath) > len(path) && path.IsPrefixOf(prevPath) {
+			c.drop(i)
+			continue
+		}
+
+		// A location appended with "-" cannot be referenced again.
+		if hasAppendToken(prevPath) {
+			break
+		}
+
+		if equalPointers(prevPath, path) {
+			switch {
+			case (prevKind == "add" || prevKind == "replace") && kind == "replace":
+				c.ops[i] = newOperation(prevKind, "", prev.path(), op.value())
+				return
+			case prevKind == "add" && kind == "remove" && c.vacated(i, path):
+				c.drop(i)
+				return
+			case (prevKind == "add" || prevKind == "replace" || prevKind == "test") && kind == "test" && op.value() != nil && prev.value() != nil && prev.value().equal(op.value()):
+				return
+			}
+			break
+		}
+
+		if (prevKind == "add" || prevKind == "replace") && prevPath.IsPrefixOf(path) {
+			if folded, ok := fold(prev, op, prevPath); ok {
+				c.ops[i] = folded
+				return
+			}
+		}
+
+		break
+	}
+
+	c.ops = append(c.ops, op)
+}
+
+func (c *composer) drop(i int) {
+	c.ops = append(c.ops[:i], c.ops[i+1:]...)
+}
+
+// vacated reports whether the location of path is known to be vacant before
+// the i-th operation, ie the last operation not commuting with it removed
+// or moved away the value at path.
+func (c *composer) vacated(i int, path Pointer) bool {
+	for j := i - 1; j >= 0; j-- {
+		prev := c.ops[j]
+
+		if independentOps(prev, c.ops[i]) {
+			continue
+		}
+
+		switch prev.kind() {
+		case "remove":
+			return equalPointers(mustPointer(prev.path()), path)
+		case "move":
+			return equalPointers(mustPointer(prev.from()), path) && !equalPointers(mustPointer(prev.path()), path)
+		}
+		return false
+	}
+
+	return false
+}
+
+// fold applies op to the value set by prev at prevPath, op having all its
+// locations within prevPath.
+func fold(prev, op Operation, prevPath Pointer) (Operation, bool) {
+	relative := func(s string) (string, bool) {
+		p := mustPointer(s)
+		if !prevPath.IsPrefixOf(p) {
+			return "", false
+		}
+		return p[len(prevPath):].String(), true
+	}
+
+	path, ok := relative(op.path())
+	if !ok {
+		return Operation{}, false
+	}
+
+	from := ""
+	if kind := op.kind(); kind == "move" || kind == "copy" {
+		from, ok = relative(op.from())
+		if !ok {
+			return Operation{}, false
+		}
+	}
+
+	value, err := json.Marshal(prev.value())
+	if err != nil {
+		return Operation{}, false
+	}
+
+	sub := newOperation(op.kind(), from, path, op.value())
+	out, err := Patch{sub}.Apply(value)
+	if err != nil {
+		return Operation{}, false
+	}
+
+	return newOperation(prev.kind(), "", prev.path(), newLazyNode((*json.RawMessage)(&out))), true
+}
+
+func isWrite(kind string) bool {
+	return kind == "add" || kind == "replace" || kind == "remove"
+}
+
+// pointers returns the locations op reads or writes.
+func pointers(op Operation) []Pointer {
+	locations := []Pointer{mustPointer(op.path())}
+	if kind := op.kind(); kind == "move" || kind == "copy" {
+		locations = append(locations, mustPointer(op.from()))
+	}
+	return locations
+}
+
+// independentOps reports whether a and b commute, whatever the document.
+func independentOps(a, b Operation) bool {
+	for _, p := range pointers(a) {
+		for _, q := range pointers(b) {
+			if !independent(p, q) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// independent reports whether the locations of p and q are disjoint and
+// changes to one cannot move the other: they differ at a token that is not
+// an array index.
+func independent(p, q Pointer) bool {
+	for i := 0; i < len(p) && i < len(q); i++ {
+		if p[i] != q[i] {
+			return !isIndexToken(p[i]) && !isIndexToken(q[i])
+		}
+	}
+	return false
+}
+
+func isIndexToken(token string) bool {
+	if token == "-" {
+		return true
+	}
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
+
+func hasAppendToken(p Pointer) bool {
+	for _, token := range p {
+		if token == "-" {
+			return true
+		}
+	}
+	return false
+}
+
+func equalPointers(p, q Pointer) bool {
+	return len(p) == len(q) && p.IsPrefixOf(q)
+}
+
+// mustPointer parses a pointer already validated by operation.validate.
+func mustPointer(s string) Pointer {
+	p, _ := ParsePointer(s)
+	return p
+}
@@ -0,0 +1,237 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodePatches(t *testing.T, patches ...string) []Patch {
+	decoded := make([]Patch, len(patches))
+	for i, p := range patches {
+		patch, err := DecodePatch([]byte(p))
+		require.NoError(t, err)
+		decoded[i] = patch
+	}
+	return decoded
+}
+
+func TestCompose(t *testing.T) {
+	doc := `{"a":{"b":1,"c":[1,2,3]},"d":"e","f":[{"g":1},{"g":2}]}`
+	testCases := []struct {
+		name     string
+		patches  []string
+		expected string
+	}{
+		{
+			"replace chain",
+			[]string{`[{"op":"replace","path":"/d","value":1}]`, `[{"op":"replace","path":"/d","value":2}]`, `[{"op":"replace","path":"/d","value":3}]`},
+			`[{"op":"replace","path":"/d","value":3}]`,
+		},
+		{
+			"add then replace",
+			[]string{`[{"op":"add","path":"/x","value":1},{"op":"replace","path":"/d","value":1}]`, `[{"op":"replace","path":"/x","value":2}]`},
+			`[{"op":"add","path":"/x","value":2},{"op":"replace","path":"/d","value":1}]`,
+		},
+		{
+			"replace then remove",
+			[]string{`[{"op":"replace","path":"/d","value":1}]`, `[{"op":"remove","path":"/d"}]`},
+			`[{"op":"replace","path":"/d","value":1},{"op":"remove","path":"/d"}]`,
+		},
+		{
+			"add then remove of a vacated location",
+			[]string{`[{"op":"remove","path":"/d"},{"op":"add","path":"/d","value":1}]`, `[{"op":"remove","path":"/d"}]`},
+			`[{"op":"remove","path":"/d"}]`,
+		},
+		{
+			"add then remove",
+			[]string{`[{"op":"add","path":"/d","value":1}]`, `[{"op":"remove","path":"/d"}]`},
+			`[{"op":"add","path":"/d","value":1},{"op":"remove","path":"/d"}]`,
+		},
+		{
+			"fold into added value",
+			[]string{`[{"op":"add","path":"/x","value":{"y":[1]}}]`, `[{"op":"add","path":"/x/y/-","value":2},{"op":"add","path":"/x/z","value":3}]`, `[{"op":"remove","path":"/x/y/0"}]`},
+			`[{"op":"add","path":"/x","value":{"y":[2],"z":3}}]`,
+		},
+		{
+			"fold move within replaced value",
+			[]string{`[{"op":"replace","path":"/a","value":{"b":1}}]`, `[{"op":"move","from":"/a/b","path":"/a/c"},{"op":"test","path":"/a","value":{"c":1}}]`},
+			`[{"op":"replace","path":"/a","value":{"c":1}}]`,
+		},
+		{
+			"descendant writes dropped",
+			[]string{`[{"op":"replace","path":"/a/b","value":2},{"op":"add","path":"/a/c/-","value":4},{"op":"replace","path":"/d","value":1}]`, `[{"op":"replace","path":"/a","value":null}]`},
+			`[{"op":"replace","path":"/d","value":1},{"op":"replace","path":"/a","value":null}]`,
+		},
+		{
+			"array indices are not reordered",
+			[]string{`[{"op":"replace","path":"/f/1/g","value":3},{"op":"remove","path":"/f/0"}]`, `[{"op":"replace","path":"/f/1/g","value":4}]`},
+			`[{"op":"replace","path":"/f/1/g","value":3},{"op":"remove","path":"/f/0"},{"op":"replace","path":"/f/1/g","value":4}]`,
+		},
+		{
+			"same array element",
+			[]string{`[{"op":"replace","path":"/f/1/g","value":3},{"op":"replace","path":"/a/b","value":2}]`, `[{"op":"replace","path":"/f/1/g","value":4}]`},
+			`[{"op":"replace","path":"/f/1/g","value":4},{"op":"replace","path":"/a/b","value":2}]`,
+		},
+		{
+			"move source is a dependency",
+			[]string{`[{"op":"replace","path":"/d","value":1},{"op":"move","from":"/d","path":"/x"}]`, `[{"op":"replace","path":"/d","value":2}]`},
+			`[{"op":"replace","path":"/d","value":1},{"from":"/d","op":"move","path":"/x"},{"op":"replace","path":"/d","value":2}]`,
+		},
+		{
+			"failing test is kept",
+			[]string{`[{"op":"replace","path":"/d","value":1}]`, `[{"op":"test","path":"/d","value":2}]`},
+			`[{"op":"replace","path":"/d","value":1},{"op":"test","path":"/d","value":2}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patches := decodePatches(t, tc.patches...)
+			composed, err := Compose(patches...)
+			require.NoError(t, err)
+			out, err := json.Marshal(composed)
+			require.NoError(t, err)
+			assert.True(t, Equal([]byte(tc.expected), out), "expected %s got %s", tc.expected, out)
+
+			expected, expectedErr := []byte(doc), error(nil)
+			for _, p := range patches {
+				if expectedErr == nil {
+					expected, expectedErr = p.Apply(expected)
+				}
+			}
+			result, err := composed.Apply([]byte(doc))
+			if expectedErr != nil {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, Equal(expected, result), "expected %s got %s", expected, result)
+		})
+	}
+}
+
+// A lenient replace adds a missing member, which the remove needs.
+func TestComposeReplaceMissingMember(t *testing.T) {
+	patches := decodePatches(t, `[{"op":"replace","path":"/k","value":1}]`, `[{"op":"remove","path":"/k"}]`)
+	expected, err := patches[0].Apply([]byte(`{}`))
+	require.NoError(t, err)
+	expected, err = patches[1].Apply(expected)
+	require.NoError(t, err)
+
+	composed, err := Compose(patches...)
+	require.NoError(t, err)
+	out, err := composed.Apply([]byte(`{}`))
+	require.NoError(t, err, "composed %v", composed)
+	assert.True(t, Equal(expected, out), "expected %s got %s", expected, out)
+}
+
+func TestComposeInvalid(t *testing.T) {
+	var patch Patch
+	require.NoError(t, json.Unmarshal([]byte(`[{"op":"frobnicate","path":"/a"}]`), &patch))
+	_, err := Compose(decodePatches(t, `[{"op":"add","path":"/a","value":1}]`)[0], patch)
+	var perr *PatchError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 1, perr.Index)
+	assert.True(t, errors.Is(err, ErrUnknownOp))
+}
+
+// diffSequence returns the patches turning each document into the next one.
+func diffSequence(t *testing.T, docs ...string) []Patch {
+	patches := make([]Patch, 0, len(docs)-1)
+	for i := 1; i < len(docs); i++ {
+		patch, err := CreatePatchWithOptions([]byte(docs[i-1]), []byte(docs[i]), DiffOptions{DetectMovesAndCopies: true})
+		require.NoError(t, err)
+		patches = append(patches, patch)
+	}
+	return patches
+}
+
+func TestComposeFixtures(t *testing.T) {
+	sequences := [][]string{
+		{simpleA, simpleB, simpleC, simpleD, simpleE, simplef, simpleG, empty, simpleA},
+		{complexBase, complexA, complexB, complexC, complexBase},
+		{hyperComplexBase, hyperComplexA, hyperComplexBase},
+		{superComplexBase, superComplexA},
+		{collectionWindowAscBefore, collectionWindowAscAfter, collectionWindowDscBefore, collectionWindowDscAfter},
+		{subArray1_current, subArray1_target, subArray2_target, subArray3_target, subArray4_target},
+	}
+
+	for i, docs := range sequences {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			patches := diffSequence(t, docs...)
+			last := []byte(docs[len(docs)-1])
+
+			composed, err := Compose(patches...)
+			require.NoError(t, err)
+			out, err := composed.Apply([]byte(docs[0]))
+			require.NoError(t, err)
+			assert.True(t, Equal(last, out), "expected %s got %s", last, out)
+
+			composed, err = ComposeOn([]byte(docs[0]), patches...)
+			require.NoError(t, err)
+			out, err = composed.Apply([]byte(docs[0]))
+			require.NoError(t, err)
+			assert.True(t, Equal(last, out), "expected %s got %s", last, out)
+		})
+	}
+}
+
+func TestComposeRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomDoc := func() string {
+		doc := map[string]interface{}{}
+		for _, k := range []string{"a", "b", "c"} {
+			switch r.Intn(4) {
+			case 0:
+			case 1:
+				doc[k] = r.Intn(3)
+			case 2:
+				doc[k] = map[string]interface{}{"x": r.Intn(3), "y": []interface{}{r.Intn(2), r.Intn(2)}}
+			default:
+				ary := make([]interface{}, r.Intn(4))
+				for i := range ary {
+					ary[i] = map[string]interface{}{"v": r.Intn(3)}
+				}
+				doc[k] = ary
+			}
+		}
+		out, _ := json.Marshal(doc)
+		return string(out)
+	}
+
+	for i := 0; i < 300; i++ {
+		docs := []string{randomDoc(), randomDoc(), randomDoc(), randomDoc()}
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			patches := diffSequence(t, docs...)
+			composed, err := Compose(patches...)
+			require.NoError(t, err)
+			out, err := composed.Apply([]byte(docs[0]))
+			require.NoError(t, err, "composed %v", composed)
+			assert.True(t, Equal([]byte(docs[3]), out), "expected %s got %s", docs[3], out)
+		})
+	}
+}
+
+func TestComposeOnTypeChange(t *testing.T) {
+	patches := decodePatches(t, `[{"op":"replace","path":"","value":[1]}]`, `[{"op":"add","path":"/-","value":2}]`)
+	composed, err := ComposeOn([]byte(`{"a":1}`), patches...)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(composed))
+	out, err := composed.Apply([]byte(`{"a":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, `[1,2]`, string(out))
+
+	composed, err = Compose(patches...)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(composed))
+	assert.Equal(t, "replace", composed[0].kind())
+
+	_, err = ComposeOn([]byte(`{}`), decodePatches(t, `[{"op":"remove","path":"/a"}]`)...)
+	assert.True(t, errors.Is(err, ErrPathNotFound))
+}
@@ -0,0 +1,148 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/goccy/go-json"
+)
+
+// Document is a JSON document kept decoded in memory, for applying a stream
+// of patches without decoding and encoding the whole document each time:
+// only the values a patch reaches are decoded. It is safe for concurrent use,
+// reads being serialized with the patches since they decode the values they
+// reach as well.
+type Document struct {
+	mu   sync.Mutex
+	root *rootDoc
+}
+
+// NewDocument decodes doc, which can have any JSON value as root.
+func NewDocument(doc []byte) (*Document, error) {
+	root, err := newRootDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{root: root}, nil
+}
+
+// Apply mutates the document according to the patch. The patch is applied
+// entirely or not at all: when an operation fails, the changes made by the
+// preceding ones are reverted.
+func (d *Document) Apply(patch Patch) error {
+	return d.ApplyWithOptions(patch, NewApplyOptions())
+}
+
+// ApplyWithOptions mutates the document according to the patch and the passed
+// in ApplyOptions, see Apply. The Indent and PreserveFormatting options are
+// not used, a nil options uses the defaults from NewApplyOptions.
+func (d *Document) ApplyWithOptions(patch Patch, options *ApplyOptions) error {
+	if options == nil {
+		options = NewApplyOptions()
+	}
+
+	if err := options.Limits.checkOperations(len(patch)); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	undo := &undoLog{}
+	a := &applier{root: d.root, doc: d.root, options: options, undo: undo}
+
+	// The size is only known once encoded.
+	if options.Limits.MaxDocumentSize > 0 {
+		doc, err := json.Marshal(d.root.node)
+		if err != nil {
+			return err
+		}
+		a.size = int64(len(doc))
+	}
+
+	for i, op := range patch {
+		c, err := compileOp(op)
+
+		if err == nil {
+			// The document outlives the patch, which must not share its
+			// values.
+			c.value = append(json.RawMessage(nil), c.value...)
+			err = a.apply(&c)
+		}
+
+		if err != nil {
+			return d.revert(undo, newPatchError(i, op, err))
+		}
+	}
+
+	// The bound checked while applying is not the exact size.
+	if options.Limits.MaxDocumentSize > 0 {
+		doc, err := json.Marshal(d.root.node)
+
+		if err == nil {
+			err = options.Limits.checkDocument(doc)
+		}
+
+		if err != nil {
+			return d.revert(undo, err)
+		}
+	}
+
+	return nil
+}
+
+// revert reverts the changes recorded in undo after the patch failed with
+// err.
+func (d *Document) revert(undo *undoLog, err error) error {
+	if rerr := d.rollback(undo); rerr != nil {
+		return fmt.Errorf("%w, reverting the patch failed: %v", err, rerr)
+	}
+
+	return err
+}
+
+// rollback reverts the changes recorded in undo.
+func (d *Document) rollback(undo *undoLog) error {
+	a := &applier{root: d.root, doc: d.root, options: &ApplyOptions{}}
+
+	for _, op := range undo.patch() {
+		c, err := compileOp(op)
+
+		if err == nil {
+			err = a.apply(&c)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Bytes returns the encoded document.
+func (d *Document) Bytes() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return json.Marshal(d.root.node)
+}
+
+// Get returns the value referenced by pointer in the document.
+func (d *Document) Get(pointer string) ([]byte, error) {
+	p, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	val, err := p.lookup(d.root)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(val)
+}
@@ -0,0 +1,165 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	d, err := NewDocument([]byte(`{"name":"John","tags":["a"],"address":{"city":"Springfield"}}`))
+	require.NoError(t, err)
+
+	patches := []string{
+		`[{"op":"replace","path":"/name","value":"Jane"},{"op":"add","path":"/tags/-","value":"b"}]`,
+		`[{"op":"move","from":"/address/city","path":"/city"},{"op":"remove","path":"/address"}]`,
+		`[{"op":"copy","from":"/tags","path":"/labels"},{"op":"add","path":"/labels/0","value":"z"}]`,
+	}
+	for _, patch := range patches {
+		p, err := DecodePatch([]byte(patch))
+		require.NoError(t, err)
+		require.NoError(t, d.Apply(p))
+	}
+
+	out, err := d.Bytes()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"Jane","tags":["a","b"],"city":"Springfield","labels":["z","a","b"]}`, string(out))
+
+	v, err := d.Get("/labels/2")
+	require.NoError(t, err)
+	assert.Equal(t, `"b"`, string(v))
+
+	_, err = d.Get("/address")
+	assert.True(t, errors.Is(err, ErrPathNotFound))
+	_, err = d.Get("address")
+	assert.True(t, errors.Is(err, ErrInvalidPointer))
+
+	// The document does not share the values of the patches.
+	value := []byte(`{"x":1}`)
+	require.NoError(t, d.Apply(Patch{{Op: "add", Path: "/value", Value: json.RawMessage(value)}}))
+	copy(value, `{"y":2}`)
+	v, err = d.Get("/value")
+	require.NoError(t, err)
+	assert.Equal(t, `{"x":1}`, string(v))
+}
+
+func TestDocumentRollback(t *testing.T) {
+	doc := `{"a":{"b":[1,2,3]},"c":"d","e":null}`
+	testCases := []string{
+		`[{"op":"add","path":"/a/b/-","value":4},{"op":"test","path":"/c","value":"x"}]`,
+		`[{"op":"remove","path":"/a/b/0"},{"op":"add","path":"/a/b/5","value":1}]`,
+		`[{"op":"move","from":"/a/b","path":"/c"},{"op":"copy","from":"/a","path":"/f"},{"op":"remove","path":"/g"}]`,
+		`[{"op":"replace","path":"","value":[]},{"op":"add","path":"/x","value":1}]`,
+		`[{"op":"move","from":"/c","path":"/a/x/y"}]`,
+		`[{"op":"add","path":"/a/b/x","value":1}]`,
+	}
+
+	for _, tc := range testCases {
+		d, err := NewDocument([]byte(doc))
+		require.NoError(t, err)
+		patch, err := DecodePatch([]byte(tc))
+		require.NoError(t, err)
+
+		err = d.Apply(patch)
+		var perr *PatchError
+		assert.True(t, errors.As(err, &perr), tc)
+
+		out, err := d.Bytes()
+		require.NoError(t, err)
+		assert.True(t, Equal([]byte(doc), out), "%s left %s", tc, out)
+	}
+
+	// With the containers created on add.
+	d, err := NewDocument([]byte(doc))
+	require.NoError(t, err)
+	options := NewApplyOptions()
+	options.EnsurePathExistsOnAdd = true
+	err = d.ApplyWithOptions(Patch{NewPatch("add", "/x/y/0/z", 1), NewPatch("add", "/e/f/-", 2), NewPatch("remove", "/g", nil)}, options)
+	assert.Error(t, err)
+	out, err := d.Bytes()
+	require.NoError(t, err)
+	assert.True(t, Equal([]byte(doc), out), "left %s", out)
+}
+
+// A Document ends up as the document given by Patch.Apply, or unchanged when
+// the patch fails.
+func TestDocumentRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	docs := []string{
+		`{"a":[1,[2,3],{"k0":4}],"b":{"k1":[5,6],"k2":7},"c":[8,9,10]}`,
+		`[[1,2,[3,4]],[5],{"k0":[6,7]},8]`,
+	}
+
+	for _, doc := range docs {
+		d, err := NewDocument([]byte(doc))
+		require.NoError(t, err)
+		current := []byte(doc)
+
+		failed := 0
+		for i := 0; i < 1000; i++ {
+			// Keep the document small, without object members taken for
+			// array indices by randomOps.
+			if n := len(pointersOf(t, current)); n < 4 || n > 40 || !indexedArrays(current) {
+				d, err = NewDocument([]byte(doc))
+				require.NoError(t, err)
+				current = []byte(doc)
+			}
+
+			// The second patch applies to the document before the first one,
+			// so may fail afterwards.
+			patch := append(randomOps(r, current, 1+r.Intn(3)), randomOps(r, current, 1+r.Intn(3))...)
+
+			expected, err := patch.Apply(current)
+			if err != nil {
+				failed++
+				expected = current
+			}
+
+			assert.Equal(t, err == nil, d.Apply(patch) == nil)
+			out, err := d.Bytes()
+			require.NoError(t, err)
+			require.True(t, Equal(expected, out), "%v: expected %s got %s", patch, expected, out)
+			current = out
+		}
+
+		assert.True(t, failed > 100, "%d patches failed", failed)
+	}
+}
+
+func TestDocumentConcurrent(t *testing.T) {
+	d, err := NewDocument([]byte(`{"counters":{},"log":[]}`))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				patch := Patch{
+					NewPatch("add", fmt.Sprintf("/counters/%d-%d", i, j), j),
+					NewPatch("add", "/log/-", fmt.Sprint(i, "-", j)),
+				}
+				assert.NoError(t, d.Apply(patch))
+
+				out, err := d.Get("/counters")
+				if assert.NoError(t, err) {
+					assert.Contains(t, string(out), fmt.Sprintf(`"%d-%d":%d`, i, j, j))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	out, err := d.Get("/log")
+	require.NoError(t, err)
+	var log []string
+	require.NoError(t, json.Unmarshal(out, &log))
+	assert.Equal(t, 400, len(log))
+}
@@ -1,6 +1,109 @@
 package jsonpatch
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTestFailed is the cause of a "test" operation whose value does not
+	// match the document.
+	ErrTestFailed = errors.New("test failed")
+	// ErrPathNotFound is the cause of an operation whose path or from
+	// location cannot be resolved in the document.
+	ErrPathNotFound = errors.New("path not found")
+	// ErrInvalidIndex is the cause of an operation using an array index that
+	// is malformed or out of bounds.
+	ErrInvalidIndex = errors.New("invalid index")
+	// ErrUnknownOp is the cause of an operation with an unknown "op" member.
+	ErrUnknownOp = errors.New("unknown operation")
+	// ErrInvalidPointer is the cause of an operation whose path or from
+	// location is not a valid JSON pointer.
+	ErrInvalidPointer = errors.New("invalid JSON pointer")
+	// ErrMissingValue is the cause of an operation lacking a required "value"
+	// member.
+	ErrMissingValue = errors.New("missing value")
+	// ErrMissingMember is the cause of a decoded operation lacking its "op",
+	// its "path", or the "from" of a "move" or "copy".
+	ErrMissingMember = errors.New("missing member")
+	// ErrInvalidMove is the cause of a "move" operation into a child of its
+	// from location, rejected by the Strict option.
+	ErrInvalidMove = errors.New("cannot move a value into one of its children")
+	// ErrInvalidPredicate is the cause of an extension operation whose value
+	// is not a valid argument of its predicate.
+	ErrInvalidPredicate = errors.New("invalid predicate")
+	// ErrNotRepresentable is returned when a change cannot be expressed as a
+	// JSON merge patch.
+	ErrNotRepresentable = errors.New("not representable as a merge patch")
+	// ErrConflict is the cause of a ConflictError.
+	ErrConflict = errors.New("conflicting operations")
+	// ErrLimitExceeded is the cause of a LimitError, an ArraySizeError and an
+	// AccumulatedCopySizeError.
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// PatchError is the error type returned when an operation of a patch cannot
+// be decoded or applied. Use errors.Is on it to check the cause against the
+// sentinel errors of this package.
+type PatchError struct {
+	// Index is the position of the operation in the patch.
+	Index int
+	// Op is the kind of the operation, empty if it has none.
+	Op string
+	// Path is the path of the operation.
+	Path string
+	// From is the from location of "move" and "copy" operations.
+	From string
+	// Err is the cause of the failure.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("jsonpatch %s operation %d does not apply: %s", e.Op, e.Index, e.Err)
+}
+
+// Unwrap returns the cause of the failure.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+func newPatchError(index int, op Operation, err error) *PatchError {
+	e := &PatchError{Index: index, Op: op.kind(), Path: op.path(), Err: err}
+	if e.Op == "move" || e.Op == "copy" {
+		e.From = op.from()
+	}
+	return e
+}
+
+// ConflictError is returned by Transform when an operation of each patch
+// touches the same location in ways that cannot be reconciled.
+type ConflictError struct {
+	// A is the position of the operation in the first patch.
+	A int
+	// B is the position of the operation in the second patch.
+	B int
+	// Path is the location the operations conflict on.
+	Path string
+}
+
+// Error implements the error interface.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("jsonpatch operations %d and %d conflict on %q", e.A, e.B, e.Path)
+}
+
+// Unwrap returns ErrConflict.
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+func errPathNotFound(path string) error {
+	return fmt.Errorf("%w: %q", ErrPathNotFound, path)
+}
+
+func errInvalidIndex(key string) error {
+	return fmt.Errorf("%w: %q", ErrInvalidIndex, key)
+}
 
 // AccumulatedCopySizeError is an error type returned when the accumulated size
 // increase caused by copy operations in a patch operation has exceeded the
@@ -20,6 +123,11 @@ func (a *AccumulatedCopySizeError) Error() string {
 	return fmt.Sprintf("Unable to complete the copy, the accumulated size increase of copy is %d, exceeding the limit %d", a.accumulated, a.limit)
 }
 
+// Unwrap returns ErrLimitExceeded.
+func (a *AccumulatedCopySizeError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
 // ArraySizeError is an error type returned when the array size has exceeded
 // the limit.
 type ArraySizeError struct {
@@ -36,3 +144,29 @@ func NewArraySizeError(l, s int) *ArraySizeError {
 func (a *ArraySizeError) Error() string {
 	return fmt.Sprintf("Unable to create array of size %d, limit is %d", a.size, a.limit)
 }
+
+// Unwrap returns ErrLimitExceeded.
+func (a *ArraySizeError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// LimitError is the error type returned when a patch, or the document it
+// produces, exceeds one of the Limits.
+type LimitError struct {
+	// Limit is the name of the field of Limits exceeded.
+	Limit string
+	// Max is the value of the limit.
+	Max int
+	// Size is the size reached.
+	Size int
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeded: %d, limit is %d", e.Limit, e.Size, e.Max)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
@@ -0,0 +1,159 @@
+package jsonpatch
+
+import "strconv"
+
+// undoLog records, while a patch is applied, the operations reverting each
+// change to the document in the order the changes happen. Its methods are
+// called right before the change and do nothing on a nil undoLog.
+type undoLog struct {
+	ops Patch
+}
+
+func (u *undoLog) record(op Operation) {
+	u.ops = append(u.ops, op)
+}
+
+// mark returns the position of the next record.
+func (u *undoLog) mark() int {
+	if u == nil {
+		return 0
+	}
+
+	return len(u.ops)
+}
+
+// failed drops the records made since mark when the change they revert
+// failed with err, and returns err.
+func (u *undoLog) failed(mark int, err error) error {
+	if u != nil && err != nil {
+		u.ops = u.ops[:mark]
+	}
+
+	return err
+}
+
+// added records how to revert adding a value under key of con, path being
+// the pointer of the location.
+func (u *undoLog) added(con container, path, key string, options *ApplyOptions) {
+	if u == nil {
+		return
+	}
+
+	switch c := con.(type) {
+	case *partialArray:
+		idx := len(*c)
+		if key != "-" {
+			idx, _ = strconv.Atoi(key)
+			if idx < 0 && options.SupportNegativeIndices {
+				idx += len(*c) + 1
+			}
+		}
+		u.record(newOperation("remove", "", arrayLocation(path, idx), nil))
+	case *partialDoc:
+		if old, ok := (*c)[key]; ok {
+			u.record(newOperation("replace", "", path, old))
+		} else {
+			u.record(newOperation("remove", "", path, nil))
+		}
+	case *rootDoc:
+		u.record(newOperation("replace", "", "", c.node))
+	}
+}
+
+// removed records how to revert removing the value under key of con.
+func (u *undoLog) removed(con container, path, key string, options *ApplyOptions) {
+	if u == nil {
+		return
+	}
+
+	switch c := con.(type) {
+	case *partialArray:
+		idx, err := strconv.Atoi(key)
+		if idx < 0 && options.SupportNegativeIndices {
+			idx += len(*c)
+		}
+		if err != nil || idx < 0 || idx >= len(*c) {
+			return
+		}
+		u.record(newOperation("add", "", arrayLocation(path, idx), (*c)[idx]))
+	case *partialDoc:
+		u.record(newOperation("add", "", path, (*c)[key]))
+	case *rootDoc:
+		u.record(newOperation("replace", "", "", c.node))
+	}
+}
+
+// replaced records how to revert setting the value under key of con.
+func (u *undoLog) replaced(con container, path, key string, options *ApplyOptions) {
+	if u == nil {
+		return
+	}
+
+	switch c := con.(type) {
+	case *partialArray:
+		old, err := c.get(key)
+		if err != nil {
+			return
+		}
+		u.record(newOperation("replace", "", path, old))
+	default:
+		u.added(con, path, key, options)
+	}
+}
+
+// moved turns the last two records, the ones of the removal and the addition
+// made by a "move" operation, into a single "move" when the addition did not
+// overwrite a value.
+func (u *undoLog) moved() {
+	if u == nil || len(u.ops) < 2 {
+		return
+	}
+
+	n := len(u.ops)
+	add, remove := u.ops[n-2], u.ops[n-1]
+
+	if add.kind() != "add" || remove.kind() != "remove" {
+		return
+	}
+
+	u.ops = append(u.ops[:n-2], newOperation("move", remove.path(), add.path(), nil))
+}
+
+// patch returns the operations reverting all the recorded changes.
+func (u *undoLog) patch() Patch {
+	inverse := make(Patch, 0, len(u.ops))
+	for i := len(u.ops) - 1; i >= 0; i-- {
+		inverse = append(inverse, u.ops[i])
+	}
+	return inverse
+}
+
+// arrayLocation returns the pointer to the element idx of the array holding
+// the location of path.
+func arrayLocation(path string, idx int) string {
+	p, err := ParsePointer(path)
+	if err != nil || len(p) == 0 {
+		return path
+	}
+	return p.Parent().Append(strconv.Itoa(idx)).String()
+}
+
+// ApplyWithInverse mutates a JSON document according to the patch, and
+// returns the new document along with the patch reverting it: applying the
+// inverse to the new document gives back doc.
+func (p Patch) ApplyWithInverse(doc []byte) ([]byte, Patch, error) {
+	undo := &undoLog{}
+
+	out, err := p.apply(doc, NewApplyOptions(), undo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, undo.patch(), nil
+}
+
+// Invert returns the patch reverting the changes made by patch to doc.
+func Invert(doc []byte, patch Patch) (Patch, error) {
+	_, inverse, err := patch.ApplyWithInverse(doc)
+	return inverse, err
+}
@@ -0,0 +1,116 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/goccy/go-json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inverseFixtures are the original and modified documents of the diff
+// fixtures, whose patches are inverted.
+var inverseFixtures = []struct {
+	name     string
+	original string
+	modified string
+}{
+	{"simple_replace", simpleA, simpleB},
+	{"simple_add", simpleA, simpleD},
+	{"simple_remove", simpleA, simpleE},
+	{"simple_mixed", simpleA, simplef},
+	{"simple_null", simpleA, simpleG},
+	{"simple_empty", simpleA, empty},
+	{"collection", collection, emptyCollection},
+	{"collection_window", collectionWindowAscBefore, collectionWindowAscAfter},
+	{"complex_replace", complexBase, complexA},
+	{"complex_nested", complexBase, complexB},
+	{"complex_add", complexBase, complexC},
+	{"hypercomplex", hyperComplexBase, hyperComplexA},
+	{"hypercomplex_reverse", hyperComplexA, hyperComplexBase},
+	{"supercomplex", superComplexBase, superComplexA},
+	{"moves", movesBase, `{"user":{"name":"Jane"},"archive":{"address":{"street":"Main St","number":42,"city":"Springfield"},"name":"John"}}`},
+}
+
+func TestApplyWithInverseFixtures(t *testing.T) {
+	for _, opts := range []DiffOptions{{}, {DetectMovesAndCopies: true}} {
+		for _, tc := range inverseFixtures {
+			t.Run(tc.name, func(t *testing.T) {
+				patch, err := CreatePatchWithOptions([]byte(tc.original), []byte(tc.modified), opts)
+				require.NoError(t, err)
+
+				modified, inverse, err := patch.ApplyWithInverse([]byte(tc.original))
+				require.NoError(t, err)
+				assert.True(t, Equal([]byte(tc.modified), modified))
+
+				restored, err := inverse.Apply(modified)
+				require.NoError(t, err)
+				assert.True(t, Equal([]byte(tc.original), restored), "expected %s got %s", tc.original, restored)
+			})
+		}
+	}
+}
+
+func TestApplyWithInverseCases(t *testing.T) {
+	for _, c := range Cases {
+		patch, err := DecodePatch([]byte(c.patch))
+		require.NoError(t, err)
+		modified, inverse, err := patch.ApplyWithInverse([]byte(c.doc))
+		require.NoError(t, err)
+		restored, err := inverse.Apply(modified)
+		require.NoError(t, err, "inverse of %s", c.patch)
+		assert.True(t, compareJSON(c.doc, string(restored)), "%s reverted to %s", c.patch, restored)
+	}
+}
+
+func TestInvert(t *testing.T) {
+	doc := []byte(`{"foo":["bar","baz"],"a":{"b":1},"c":null}`)
+	testCases := []struct {
+		patch   string
+		inverse string
+	}{
+		{`[{"op":"add","path":"/foo/-","value":"qux"}]`, `[{"op":"remove","path":"/foo/2"}]`},
+		{`[{"op":"add","path":"/foo/-1","value":"qux"}]`, `[{"op":"remove","path":"/foo/2"}]`},
+		{`[{"op":"remove","path":"/foo/-1"}]`, `[{"op":"add","path":"/foo/1","value":"baz"}]`},
+		{`[{"op":"add","path":"/a/b","value":2}]`, `[{"op":"replace","path":"/a/b","value":1}]`},
+		{`[{"op":"add","path":"/d","value":2}]`, `[{"op":"remove","path":"/d"}]`},
+		{`[{"op":"remove","path":"/c"}]`, `[{"op":"add","path":"/c","value":null}]`},
+		{`[{"op":"replace","path":"/foo/0","value":1}]`, `[{"op":"replace","path":"/foo/0","value":"bar"}]`},
+		{`[{"op":"move","from":"/foo/0","path":"/foo/-"}]`, `[{"op":"move","path":"/foo/0","from":"/foo/1"}]`},
+		{`[{"op":"move","from":"/a","path":"/b"}]`, `[{"op":"move","path":"/a","from":"/b"}]`},
+		{`[{"op":"move","from":"/a","path":"/c"}]`, `[{"op":"replace","path":"/c","value":null},{"op":"add","path":"/a","value":{"b":1}}]`},
+		{`[{"op":"copy","from":"/a","path":"/foo/0"}]`, `[{"op":"remove","path":"/foo/0"}]`},
+		{`[{"op":"test","path":"/a/b","value":1}]`, `[]`},
+		{`[{"op":"replace","path":"","value":1}]`, `[{"op":"replace","path":"","value":{"a":{"b":1},"c":null,"foo":["bar","baz"]}}]`},
+		{`[{"op":"remove","path":"/a/b"},{"op":"add","path":"/a/c","value":2}]`, `[{"op":"remove","path":"/a/c"},{"op":"add","path":"/a/b","value":1}]`},
+	}
+
+	for _, tc := range testCases {
+		patch, err := DecodePatch([]byte(tc.patch))
+		require.NoError(t, err)
+		inverse, err := Invert(doc, patch)
+		require.NoError(t, err)
+		out, err := json.Marshal(inverse)
+		require.NoError(t, err)
+		assert.Equal(t, tc.inverse, string(out), tc.patch)
+
+		modified, err := patch.Apply(doc)
+		require.NoError(t, err)
+		restored, err := inverse.Apply(modified)
+		require.NoError(t, err)
+		assert.True(t, Equal(doc, restored), "%s reverted to %s", tc.patch, restored)
+	}
+
+	patch, err := DecodePatch([]byte(`[{"op":"add","path":"/settings/ui/theme","value":"dark"}]`))
+	require.NoError(t, err)
+	undo := &undoLog{}
+	modified, err := patch.apply(doc, &ApplyOptions{EnsurePathExistsOnAdd: true}, undo)
+	require.NoError(t, err)
+	restored, err := undo.patch().Apply(modified)
+	require.NoError(t, err)
+	assert.True(t, Equal(doc, restored))
+
+	_, err = Invert(doc, Patch{newOperation("remove", "", "/x", nil)})
+	assert.Error(t, err)
+}
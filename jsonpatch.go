@@ -2,7 +2,9 @@ package jsonpatch
 
 import (
 	"bytes"
+	stdjson "encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -11,11 +13,44 @@ import (
 
 var errBadMergeTypes = fmt.Errorf("mismatched json documents")
 
-// Operation operation struct
+// DiffOptions configures the operations generated by CreatePatchWithOptions.
+// The zero value compares arrays by value and only emits add, remove and
+// replace operations.
+type DiffOptions struct {
+	// DetectMovesAndCopies replaces a value removed in one place and added
+	// unchanged in another with a "move", and the addition of a value already
+	// present and untouched elsewhere with a "copy".
+	DetectMovesAndCopies bool
+	// ArrayKeys maps the pointer of an array to the member identifying its
+	// elements, which are then matched by that member in both documents,
+	// emitting a "move" for the ones that changed position and diffing the
+	// matched pairs. A "*" token of the pointer matches any member or index,
+	// eg: "/goods/*/topping".
+	ArrayKeys map[string]string
+	// DefaultArrayKey is the member identifying the elements of the arrays
+	// not listed in ArrayKeys. Empty to match elements by value.
+	DefaultArrayKey string
+}
+
+// Operation is a single operation of a patch. Value holds the value of the
+// "add", "replace" and "test" operations: decoded operations keep it as a
+// json.RawMessage, and those created by the diff as decoded JSON values. Not
+// negates the condition of the extension operations enabled by
+// ApplyOptions.Predicates.
 type Operation struct {
-	Operation string      `json:"op"`
-	Path      string      `json:"path"`
-	Value     interface{} `json:"value,omitempty"`
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+	Not   bool        `json:"not,omitempty"`
+
+	// old is the value removed by a remove operation.
+	old interface{}
+	// member is set when Path points to an object member, not an array element.
+	member bool
+	// missing lists the required members absent from a decoded operation,
+	// whose fields are left empty.
+	missing []string
 }
 
 // resemblesJSONArray indicates whether the byte-slice "appears" to be
@@ -33,30 +68,121 @@ func resemblesJSONArray(input []byte) bool {
 }
 
 // JSON returns a patch operation Json representation
-func (j *Operation) JSON() string {
+func (j Operation) JSON() string {
 	b, _ := json.Marshal(j)
 	return string(b)
 }
 
-// MarshalJSON for patch operations
-func (j *Operation) MarshalJSON() ([]byte, error) {
+// MarshalJSON for patch operations, "from" is only written for "move" and
+// "copy" operations, "value" for the ones having one, always for "add" and
+// "replace", and "not" when set.
+// The members missing from a decoded operation are left out.
+func (j Operation) MarshalJSON() ([]byte, error) {
 	var b bytes.Buffer
 	b.WriteString("{")
-	b.WriteString(fmt.Sprintf(`"op":"%s"`, j.Operation))
-	b.WriteString(fmt.Sprintf(`,"path":"%s"`, j.Path))
+	if !j.lacks("op") {
+		if err := writeMember(&b, "op", j.Op); err != nil {
+			return nil, err
+		}
+	}
+	if !j.lacks("path") {
+		if err := writeMember(&b, "path", j.Path); err != nil {
+			return nil, err
+		}
+	}
+	if (j.Op == "move" || j.Op == "copy") && !j.lacks("from") {
+		if err := writeMember(&b, "from", j.From); err != nil {
+			return nil, err
+		}
+	}
 	// Consider omitting Value for non-nullable operations.
-	if j.Value != nil || j.Operation == "replace" || j.Operation == "add" {
-		v, err := json.Marshal(j.Value)
-		if err != nil {
+	if j.Value != nil || j.Op == "replace" || j.Op == "add" {
+		if err := writeMember(&b, "value", j.Value); err != nil {
+			return nil, err
+		}
+	}
+	if j.Not {
+		if err := writeMember(&b, "not", true); err != nil {
 			return nil, err
 		}
-		b.WriteString(`,"value":`)
-		b.Write(v)
 	}
 	b.WriteString("}")
 	return b.Bytes(), nil
 }
 
+// writeMember writes the member name of an object opened in b.
+func writeMember(b *bytes.Buffer, name string, v interface{}) error {
+	if b.Len() > 1 {
+		b.WriteString(",")
+	}
+	b.WriteString(`"` + name + `":`)
+	return writeJSON(b, v)
+}
+
+func writeJSON(b *bytes.Buffer, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b.Write(buf)
+	return nil
+}
+
+// lacks tells if the member name was missing from the decoded operation.
+func (j Operation) lacks(name string) bool {
+	for _, m := range j.missing {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON for patch operations, the value is kept as a json.RawMessage
+// (a null value is not a missing one). A missing "op", "path", or "from" of a
+// "move" or "copy", is left empty and recorded: the operation never applies,
+// failing with ErrMissingMember, and is encoded again without the member.
+// "not" is only decoded for "test" and the extension operations.
+func (j *Operation) UnmarshalJSON(data []byte) error {
+	// The member names are matched exactly, unlike the fields of a struct.
+	var members map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	var op Operation
+	for _, m := range []struct {
+		name  string
+		field *string
+	}{{"op", &op.Op}, {"path", &op.Path}, {"from", &op.From}} {
+		// A null member is missing as well.
+		var v *string
+		if raw, ok := members[m.name]; ok {
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+		}
+		if v != nil {
+			*m.field = *v
+		} else if m.name != "from" || op.Op == "move" || op.Op == "copy" {
+			op.missing = append(op.missing, m.name)
+		}
+	}
+	if raw, ok := members["value"]; ok {
+		op.Value = raw
+	}
+	// "not" is an unknown member of the other operations, which is ignored.
+	if raw, ok := members["not"]; ok && (op.Op == "test" || isPredicate(op.Op)) {
+		if err := json.Unmarshal(raw, &op.Not); err != nil {
+			return err
+		}
+	}
+
+	*j = op
+	return nil
+}
+
 // ByPath array of patch operation structs
 type ByPath []Operation
 
@@ -66,172 +192,87 @@ func (a ByPath) Less(i, j int) bool { return a[i].Path < a[j].Path }
 
 // NewPatch creates a patch operation struct
 func NewPatch(operation, path string, value interface{}) Operation {
-	return Operation{Operation: operation, Path: path, Value: value}
+	return Operation{Op: operation, Path: path, Value: value}
 }
 
 // CreatePatch creates a patch as specified in http://jsonpatch.com/
 //
 // 'a' is original, 'b' is the modified document. Both are to be given as json encoded content.
-// The function will return an array of Operations
+// The function will return a Patch, which can be applied as is.
 //
 // An error will be returned if any of the two documents are invalid.
-func CreatePatch(a, b []byte) ([]Operation, error) {
+func CreatePatch(a, b []byte) (Patch, error) {
+	return CreatePatchWithOptions(a, b, DiffOptions{})
+}
+
+// CreatePatchWithOptions creates a patch like CreatePatch, configured by opts.
+func CreatePatchWithOptions(a, b []byte, opts DiffOptions) (Patch, error) {
 	if bytes.Equal(a, b) {
-		return []Operation{}, nil
+		return Patch{}, nil
 	}
 	originalResemblesArray := resemblesJSONArray(a)
 	modifiedResemblesArray := resemblesJSONArray(b)
+	var patch []Operation
+	var err error
+	switch {
 	// Do both byte-slices seem like JSON arrays?
-	if originalResemblesArray && modifiedResemblesArray {
-		original := []json.RawMessage{}
-		modified := []json.RawMessage{}
+	case originalResemblesArray && modifiedResemblesArray:
+		patch, err = diffArrays(a, b, "", []Operation{}, &opts)
+	// Are both byte-slices are not arrays? Then they are likely JSON objects...
+	case !originalResemblesArray && !modifiedResemblesArray:
+		patch, err = diffObjects(a, b, "", []Operation{}, &opts)
+	// None of the above? Then return an error because of mismatched types.
+	default:
+		return nil, errBadMergeTypes
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		d := json.NewDecoder(bytes.NewReader(a))
-		d.UseNumber()
-		err := d.Decode(&original)
-		if err != nil {
-			return nil, err
-		}
-		db := json.NewDecoder(bytes.NewReader(b))
-		db.UseNumber()
-		err = db.Decode(&modified)
-		if err != nil {
+	if opts.DetectMovesAndCopies {
+		if patch, err = detectMovesAndCopies(a, b, patch); err != nil {
 			return nil, err
 		}
-
-		patch := []Operation{}
-		path := ""
-
-		keysModified := map[int]bool{}
-		keysOriginal := map[int]bool{}
-		for k := range original {
-			keysOriginal[k] = true
-		}
-
-		if len(modified) == len(original) && len(original) > 2 {
-			// moving window of collections in ascending order
-			diffAsc := 0
-			length := len(modified) - 1
-			for key := range modified {
-				// first element of the original cant be found in the modified
-				if key < length && string(original[0]) == string(modified[key]) {
-					diffAsc++
-					break
-				}
-				// last element of the modified cant be found in the original
-				if key > 0 && string(modified[length]) == string(original[key]) {
-					diffAsc++
-					break
-				}
-				// other than the first original and last modified all elements are the same
-				if key < length && string(original[key+1]) != string(modified[key]) {
-					diffAsc++
-					break
-				}
-			}
-
-			if diffAsc == 0 {
-				pFirst := makePath(path, 0)
-				pLast := makePath(path, length)
-				patch = append([]Operation{NewPatch("add", pLast, modified[length])}, patch...)
-				patch = append([]Operation{NewPatch("remove", pFirst, nil)}, patch...)
-				return patch, nil
-			}
-
-			// moving window of collections in descending order
-			diffDsc := 0
-			for key := range modified {
-				// first element of the modified cant be found in the original
-				if key < length && string(modified[0]) == string(original[key]) {
-					diffDsc++
-					break
-				}
-				// last element of the original cant be found in the modified
-				if key > 0 && string(original[length]) == string(modified[key]) {
-					diffDsc++
-					break
-				}
-				// other than the first modified and last original all elements are the same
-				if key < length && string(modified[key+1]) != string(original[key]) {
-					diffDsc++
-					break
-				}
-			}
-
-			if diffDsc == 0 {
-				pFirst := makePath(path, 0)
-				pLast := makePath(path, length+1)
-				patch = append([]Operation{NewPatch("remove", pLast, nil)}, patch...)
-				patch = append([]Operation{NewPatch("add", pFirst, modified[0])}, patch...)
-				return patch, nil
-			}
-		}
-
-		for key, bv := range modified {
-			keysModified[key] = true
-			p := makePath(path, key)
-			_, found := keysOriginal[key]
-			// value was added
-			if !found {
-				patch = append(patch, NewPatch("add", p, bv))
-				continue
-			}
-			av := original[key]
-			// If types have changed, replace completely
-			if !sameRawType(av, bv) {
-				patch = append(patch, NewPatch("replace", p, bv))
-				continue
-			}
-			// Types are the same, compare values
-			patch, err = diffObjects(av, bv, "/"+strconv.Itoa(key)+"/", patch)
-			if err != nil {
-				return nil, err
-			}
-		}
-		// Now add all deleted values as nil
-		// Collect removes and sort in descending order so indices remain valid when applying
-		removes := make([]int, 0)
-		for key := range original {
-			_, found := keysModified[key]
-			if !found {
-				removes = append(removes, key)
-			}
-		}
-		sortDescending(removes)
-		for _, key := range removes {
-			p := makePath(path, key)
-			patch = append(patch, NewPatch("remove", p, nil))
-		}
-
-		return patch, nil
 	}
 
-	// Are both byte-slices are not arrays? Then they are likely JSON objects...
-	if !originalResemblesArray && !modifiedResemblesArray {
-		return diffObjects(a, b, "", []Operation{})
+	// The removed values and member flags are only used to detect moves, the
+	// patch must not keep them alive.
+	for i := range patch {
+		patch[i].old = nil
+		patch[i].member = false
 	}
 
-	// None of the above? Then return an error because of mismatched types.
-	return nil, errBadMergeTypes
+	return patch, nil
 }
 
-func diffObjects(a, b []byte, key string, patch []Operation) ([]Operation, error) {
+func diffObjects(a, b []byte, key string, patch []Operation, opts *DiffOptions) ([]Operation, error) {
 	aI := map[string]interface{}{}
 	bI := map[string]interface{}{}
-	d := json.NewDecoder(bytes.NewReader(a))
-	d.UseNumber()
-	err := d.Decode(&aI)
+	err := decodeNumbers(a, &aI)
 	if err != nil {
 		return nil, err
 	}
-	db := json.NewDecoder(bytes.NewReader(b))
-	db.UseNumber()
-	err = db.Decode(&bI)
+	err = decodeNumbers(b, &bI)
 	if err != nil {
 		return nil, err
 	}
 
-	return diff(aI, bI, key, patch)
+	return diff(aI, bI, key, patch, opts)
+}
+
+func diffArrays(a, b []byte, key string, patch []Operation, opts *DiffOptions) ([]Operation, error) {
+	aI := []interface{}{}
+	bI := []interface{}{}
+	err := decodeNumbers(a, &aI)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeNumbers(b, &bI)
+	if err != nil {
+		return nil, err
+	}
+
+	return compareArray(aI, bI, key, patch, opts)
 }
 
 // Returns true if the values matches (must be json types)
@@ -276,44 +317,41 @@ func matchesValue(av, bv interface{}) bool {
 	return false
 }
 
-// From http://tools.ietf.org/html/rfc6901#section-4 :
-//
-// Evaluation of each reference token begins by decoding any escaped
-// character sequence.  This is performed by first transforming any
-// occurrence of the sequence '~1' to '/', and then transforming any
-// occurrence of the sequence '~0' to '~'.
-//   TODO decode support:
-//   var rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
-
-var rfc6901Encoder = strings.NewReplacer("~", "~0", "/", "~1")
-
+// makePath appends newPart to the escaped pointer path.
 func makePath(path string, newPart interface{}) string {
 	var key string
 	switch v := newPart.(type) {
 	case int:
 		key = strconv.Itoa(v)
 	case string:
-		key = rfc6901Encoder.Replace(v)
+		key = EscapeToken(v)
 	default:
-		key = rfc6901Encoder.Replace(fmt.Sprintf("%v", newPart))
-	}
-	if path == "" {
-		return "/" + key
-	}
-	if strings.HasSuffix(path, "/") {
-		return path + key
+		key = EscapeToken(fmt.Sprintf("%v", newPart))
 	}
 	return path + "/" + key
 }
 
 // diff returns the (recursive) difference between a and b as an array of Operations.
-func diff(a, b map[string]interface{}, path string, patch []Operation) ([]Operation, error) {
-	for key, bv := range b {
+// Keys are visited in ascending order, so the same documents always produce
+// the same operations.
+func diff(a, b map[string]interface{}, path string, patch []Operation, opts *DiffOptions) ([]Operation, error) {
+	for _, key := range sortedKeys(a, b) {
 		p := makePath(path, key)
-		av, ok := a[key]
+		av, inA := a[key]
+		bv, inB := b[key]
+		// value was removed
+		if !inB {
+			op := NewPatch("remove", p, nil)
+			op.old = av
+			op.member = true
+			patch = append(patch, op)
+			continue
+		}
 		// value was added
-		if !ok {
-			patch = append(patch, NewPatch("add", p, bv))
+		if !inA {
+			op := NewPatch("add", p, bv)
+			op.member = true
+			patch = append(patch, op)
 			continue
 		}
 		// If types have changed, replace completely
@@ -323,20 +361,27 @@ func diff(a, b map[string]interface{}, path string, patch []Operation) ([]Operat
 		}
 		// Types are the same, compare values
 		var err error
-		patch, err = handleValues(av, bv, p, patch)
+		patch, err = handleValues(av, bv, p, patch, opts)
 		if err != nil {
 			return nil, err
 		}
 	}
-	// Now add all deleted values as nil
+	return patch, nil
+}
+
+// sortedKeys returns the union of the keys of `a` and `b` in ascending order.
+func sortedKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(b))
+	for key := range b {
+		keys = append(keys, key)
+	}
 	for key := range a {
-		_, found := b[key]
-		if !found {
-			p := makePath(path, key)
-			patch = append(patch, NewPatch("remove", p, nil))
+		if _, ok := b[key]; !ok {
+			keys = append(keys, key)
 		}
 	}
-	return patch, nil
+	sort.Strings(keys)
+	return keys
 }
 
 // sameType checks if two interface values have the same underlying type
@@ -364,36 +409,12 @@ func sameType(a, b interface{}) bool {
 	return false
 }
 
-// sameRawType checks if two json.RawMessage values represent the same JSON type.
-func sameRawType(a, b json.RawMessage) bool {
-	if len(a) == 0 || len(b) == 0 {
-		return len(a) == len(b)
-	}
-	// Compare first non-whitespace character to determine type
-	aType := jsonType(a)
-	bType := jsonType(b)
-	return aType == bType
-}
-
-// jsonType returns a byte representing the JSON type based on first character.
-func jsonType(data json.RawMessage) byte {
-	for _, c := range data {
-		switch c {
-		case ' ', '\t', '\n', '\r':
-			continue
-		default:
-			return c
-		}
-	}
-	return 0
-}
-
-func handleValues(av, bv interface{}, p string, patch []Operation) ([]Operation, error) {
+func handleValues(av, bv interface{}, p string, patch []Operation, opts *DiffOptions) ([]Operation, error) {
 	var err error
 	switch at := av.(type) {
 	case map[string]interface{}:
 		bt := bv.(map[string]interface{})
-		patch, err = diff(at, bt, p, patch)
+		patch, err = diff(at, bt, p, patch, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -406,15 +427,10 @@ func handleValues(av, bv interface{}, p string, patch []Operation) ([]Operation,
 		if !ok {
 			// array replaced by non-array
 			patch = append(patch, NewPatch("replace", p, bv))
-		} else if len(at) != len(bt) {
-			// arrays are not the same length
-			patch = append(patch, compareArray(at, bt, p)...)
 		} else {
-			for i := range bt {
-				patch, err = handleValues(at[i], bt[i], makePath(p, i), patch)
-				if err != nil {
-					return nil, err
-				}
+			patch, err = compareArray(at, bt, p, patch, opts)
+			if err != nil {
+				return nil, err
 			}
 		}
 	case nil:
@@ -443,79 +459,226 @@ func sortDescending(s []int) {
 	}
 }
 
-// arrayDiff represents a value with its count for handling duplicates.
-type arrayDiff struct {
-	indices []int
-}
-
 // https://github.com/mattbaird/jsonpatch/pull/4
-// compareArray generates remove and add operations for `av` and `bv`.
-func compareArray(av, bv []interface{}, p string) []Operation {
-	// Build hash map of bv elements with their indices
-	bvMap := make(map[string]*arrayDiff, len(bv))
-	for i, v := range bv {
-		h := hashValue(v)
-		if entry, ok := bvMap[h]; ok {
-			entry.indices = append(entry.indices, i)
-		} else {
-			bvMap[h] = &arrayDiff{indices: []int{i}}
+// compareArray generates the operations turning `av` into `bv`.
+//
+// Elements are matched through the longest common subsequence of their
+// hashes, the elements left between two matches are paired up and diffed in
+// place, and the remainder is removed or added. Indices refer to the array as
+// it is after the preceding operations, so the result can be applied
+// sequentially; elements appended past the end of `av` use the "-" index.
+func compareArray(av, bv []interface{}, p string, patch []Operation, opts *DiffOptions) ([]Operation, error) {
+	if key := opts.arrayKey(p); key != "" {
+		if aKeys, bKeys, ok := elementKeys(av, bv, key); ok {
+			return compareKeyedArray(av, bv, aKeys, bKeys, p, patch, opts)
 		}
 	}
 
-	// Build hash map of av elements with their indices
-	avMap := make(map[string]*arrayDiff, len(av))
+	ah := make([]string, len(av))
 	for i, v := range av {
-		h := hashValue(v)
-		if entry, ok := avMap[h]; ok {
-			entry.indices = append(entry.indices, i)
-		} else {
-			avMap[h] = &arrayDiff{indices: []int{i}}
+		ah[i] = hashValue(v)
+	}
+	bh := make([]string, len(bv))
+	for i, v := range bv {
+		bh[i] = hashValue(v)
+	}
+
+	var err error
+	// idx is the position in the array being patched.
+	idx, i, j := 0, 0, 0
+	// A sentinel match past the end of both arrays flushes the last hunk.
+	for _, m := range append(lcs(ah, bh), match{len(av), len(bv)}) {
+		removed, added := m.a-i, m.b-j
+		paired := removed
+		if added < paired {
+			paired = added
+		}
+		for k := 0; k < paired; k++ {
+			at, bt := av[i+k], bv[j+k]
+			if !sameType(at, bt) {
+				patch = append(patch, NewPatch("replace", makePath(p, idx+k), bt))
+				continue
+			}
+			patch, err = handleValues(at, bt, makePath(p, idx+k), patch, opts)
+			if err != nil {
+				return nil, err
+			}
 		}
+		// Remove in descending order so each index still matches `av`.
+		for k := removed - 1; k >= paired; k-- {
+			patch = append(patch, NewPatch("remove", makePath(p, idx+k), nil))
+		}
+		trailing := m.a == len(av)
+		for k := paired; k < added; k++ {
+			if trailing {
+				patch = append(patch, NewPatch("add", makePath(p, "-"), bv[j+k]))
+				continue
+			}
+			patch = append(patch, NewPatch("add", makePath(p, idx+k), bv[j+k]))
+		}
+		idx += added + 1
+		i, j = m.a+1, m.b+1
 	}
 
-	// Find elements to remove (in av but not in bv, or more occurrences in av)
-	removes := make([]int, 0)
-	for h, avEntry := range avMap {
-		bvEntry, ok := bvMap[h]
-		if !ok {
-			// All occurrences need to be removed
-			removes = append(removes, avEntry.indices...)
-		} else if len(avEntry.indices) > len(bvEntry.indices) {
-			// Remove excess occurrences
-			excess := len(avEntry.indices) - len(bvEntry.indices)
-			removes = append(removes, avEntry.indices[len(avEntry.indices)-excess:]...)
+	return patch, nil
+}
+
+// arrayKey returns the member identifying the elements of the array at `p`.
+func (o *DiffOptions) arrayKey(p string) string {
+	if key, ok := o.ArrayKeys[p]; ok {
+		return key
+	}
+	patterns := make([]string, 0, len(o.ArrayKeys))
+	for pattern := range o.ArrayKeys {
+		patterns = append(patterns, pattern)
+	}
+	// The first matching pattern in ascending order wins.
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matchPointer(pattern, p) {
+			return o.ArrayKeys[pattern]
 		}
 	}
+	return o.DefaultArrayKey
+}
 
-	// Sort removes in descending order so indices remain valid when applying patch
-	sortDescending(removes)
+// matchPointer reports whether the pointer `p` matches `pattern`, where a "*"
+// token matches any single token.
+func matchPointer(pattern, p string) bool {
+	patternTokens := strings.Split(pattern, "/")
+	tokens := strings.Split(p, "/")
+	if len(patternTokens) != len(tokens) {
+		return false
+	}
+	for i, token := range patternTokens {
+		if token != "*" && token != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
 
-	retval := make([]Operation, 0, len(removes))
-	for _, idx := range removes {
-		retval = append(retval, NewPatch("remove", makePath(p, idx), nil))
+// elementKeys hashes the `key` member of every element of `av` and `bv`.
+// It is not ok unless all the elements are objects holding a key that is
+// unique within their array.
+func elementKeys(av, bv []interface{}, key string) ([]string, []string, bool) {
+	hashKeys := func(ary []interface{}) ([]string, bool) {
+		keys := make([]string, len(ary))
+		seen := make(map[string]bool, len(ary))
+		for i, v := range ary {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			id, ok := obj[key]
+			if !ok {
+				return nil, false
+			}
+			h := hashValue(id)
+			if seen[h] {
+				return nil, false
+			}
+			seen[h] = true
+			keys[i] = h
+		}
+		return keys, true
+	}
+	aKeys, ok := hashKeys(av)
+	if !ok {
+		return nil, nil, false
+	}
+	bKeys, ok := hashKeys(bv)
+	if !ok {
+		return nil, nil, false
 	}
+	return aKeys, bKeys, true
+}
 
-	// Find elements to add (in bv but not in av, or more occurrences in bv)
-	adds := make([]int, 0)
-	for h, bvEntry := range bvMap {
-		avEntry, ok := avMap[h]
-		if !ok {
-			// All occurrences need to be added
-			adds = append(adds, bvEntry.indices...)
-		} else if len(bvEntry.indices) > len(avEntry.indices) {
-			// Add excess occurrences
-			excess := len(bvEntry.indices) - len(avEntry.indices)
-			adds = append(adds, bvEntry.indices[len(bvEntry.indices)-excess:]...)
+// compareKeyedArray generates the operations turning `av` into `bv`, arrays
+// of objects matched by the hashed identifiers `aKeys` and `bKeys`.
+//
+// Elements missing from `bv` are removed first. The longest common
+// subsequence of the remaining identifiers stays in place, every other
+// element is then moved, or added, right after its predecessor in `bv`, so
+// each one is relocated once. Finally the matched pairs are diffed at their
+// new position.
+func compareKeyedArray(av, bv []interface{}, aKeys, bKeys []string, p string, patch []Operation, opts *DiffOptions) ([]Operation, error) {
+	inB := make(map[string]bool, len(bKeys))
+	for _, k := range bKeys {
+		inB[k] = true
+	}
+	inA := make(map[string]int, len(aKeys))
+	for i, k := range aKeys {
+		inA[k] = i
+	}
+
+	for i := len(aKeys) - 1; i >= 0; i-- {
+		if !inB[aKeys[i]] {
+			patch = append(patch, NewPatch("remove", makePath(p, i), nil))
 		}
 	}
 
-	// Sort adds in ascending order for proper patch application
-	sortAscending(adds)
-	for _, idx := range adds {
-		retval = append(retval, NewPatch("add", makePath(p, idx), bv[idx]))
+	cur := make([]string, 0, len(bKeys))
+	for _, k := range aKeys {
+		if inB[k] {
+			cur = append(cur, k)
+		}
+	}
+	common := make([]string, 0, len(cur))
+	for _, k := range bKeys {
+		if _, ok := inA[k]; ok {
+			common = append(common, k)
+		}
+	}
+	stable := make(map[string]bool, len(cur))
+	for _, m := range lcs(cur, common) {
+		stable[cur[m.a]] = true
 	}
 
-	return retval
+	indexOf := func(k string) int {
+		for i, c := range cur {
+			if c == k {
+				return i
+			}
+		}
+		return -1
+	}
+	for j, k := range bKeys {
+		if stable[k] {
+			continue
+		}
+		c := indexOf(k)
+		if c >= 0 {
+			cur = append(cur[:c], cur[c+1:]...)
+		}
+		t := 0
+		if j > 0 {
+			t = indexOf(bKeys[j-1]) + 1
+		}
+		switch {
+		case c == t:
+		case c >= 0:
+			move := NewPatch("move", makePath(p, t), nil)
+			move.From = makePath(p, c)
+			patch = append(patch, move)
+		case t == len(cur):
+			patch = append(patch, NewPatch("add", makePath(p, "-"), bv[j]))
+		default:
+			patch = append(patch, NewPatch("add", makePath(p, t), bv[j]))
+		}
+		cur = append(cur[:t], append([]string{k}, cur[t:]...)...)
+	}
+
+	var err error
+	for j, k := range bKeys {
+		if i, ok := inA[k]; ok {
+			patch, err = handleValues(av[i], bv[j], makePath(p, j), patch, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return patch, nil
 }
 
 // sortAscending sorts a slice of ints in ascending order.
@@ -526,3 +689,124 @@ func sortAscending(s []int) {
 		}
 	}
 }
+
+// detectMovesAndCopies rewrites the operations of `patch`, the diff between
+// the `a` and `b` documents, pairing each removed member with an addition of
+// the same value into a "move", and turning additions of values that are
+// present and unchanged in both documents into a "copy".
+//
+// The operations emitted by the diff never affect the location of a member
+// touched by an earlier operation, so a move can take the place of the later
+// of the pair. Array elements shift on removal, so only members are moved.
+func detectMovesAndCopies(a, b []byte, patch []Operation) ([]Operation, error) {
+	av, err := decodeValue(a)
+	if err != nil {
+		return nil, err
+	}
+	bv, err := decodeValue(b)
+	if err != nil {
+		return nil, err
+	}
+	sources := map[string]string{}
+	collectUnchanged(av, bv, "", sources)
+
+	removed := map[string][]int{}
+	for i, op := range patch {
+		if op.Op == "remove" && op.member {
+			h := hashValue(op.old)
+			removed[h] = append(removed[h], i)
+		}
+	}
+
+	dropped := make([]bool, len(patch))
+	for i := range patch {
+		op := patch[i]
+		if op.Op != "add" {
+			continue
+		}
+		h := hashValue(op.Value)
+		if candidates := removed[h]; len(candidates) > 0 {
+			r := candidates[0]
+			// The addition of an array element can't be postponed.
+			if r < i || op.member {
+				removed[h] = candidates[1:]
+				move := Operation{Op: "move", Path: op.Path, From: patch[r].Path, member: op.member}
+				if r < i {
+					patch[i] = move
+					dropped[r] = true
+				} else {
+					patch[r] = move
+					dropped[i] = true
+				}
+				continue
+			}
+		}
+		// Only copy when the pointer is shorter than the value.
+		if from, ok := sources[h]; ok && len(from) < len(h) {
+			patch[i] = Operation{Op: "copy", Path: op.Path, From: from, member: op.member}
+		}
+	}
+
+	result := make([]Operation, 0, len(patch))
+	for i, op := range patch {
+		if !dropped[i] {
+			result = append(result, op)
+		}
+	}
+	return result, nil
+}
+
+// collectUnchanged indexes by hash the values identical in `av` and `bv` that
+// are reachable through object members only, so their pointers stay valid
+// while a patch is applied. The shortest pointer is kept for each value.
+func collectUnchanged(av, bv interface{}, p string, sources map[string]string) {
+	at, ok := av.(map[string]interface{})
+	if !ok {
+		return
+	}
+	bt, ok := bv.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, bValue := range bt {
+		aValue, ok := at[key]
+		if !ok {
+			continue
+		}
+		path := makePath(p, key)
+		h := hashValue(bValue)
+		if h != hashValue(aValue) {
+			collectUnchanged(aValue, bValue, path, sources)
+			continue
+		}
+		if from, ok := sources[h]; !ok || len(path) < len(from) || (len(path) == len(from) && path < from) {
+			sources[h] = path
+		}
+		collectUnchanged(aValue, bValue, path, sources)
+	}
+}
+
+// validJSON tells if buf holds a single JSON value. The Valid function of
+// go-json rejects the objects holding only spaces, such as "{ }".
+func validJSON(buf []byte) bool {
+	var raw json.RawMessage
+	return json.Unmarshal(buf, &raw) == nil
+}
+
+// decodeNumbers decodes buf into v, keeping numbers as json.Number. The
+// decoder of encoding/json is used, the one of go-json failing on the objects
+// holding only spaces.
+func decodeNumbers(buf []byte, v interface{}) error {
+	d := stdjson.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func decodeValue(doc []byte) (interface{}, error) {
+	var v interface{}
+	err := decodeNumbers(doc, &v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
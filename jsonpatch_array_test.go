@@ -0,0 +1,144 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func applyOperations(t *testing.T, doc []byte, ops []Operation) []byte {
+	result, err := Patch(ops).Apply(doc)
+	require.NoError(t, err, "patch %v", ops)
+	return result
+}
+
+func TestLCS(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a, b     []string
+		expected []match
+	}{
+		{"empty", []string{}, []string{}, []match{}},
+		{"equal", []string{"a", "b"}, []string{"a", "b"}, []match{{0, 0}, {1, 1}}},
+		{"disjoint", []string{"a", "b"}, []string{"c", "d"}, []match{}},
+		{"insert", []string{"a", "c"}, []string{"a", "b", "c"}, []match{{0, 0}, {1, 2}}},
+		{"remove", []string{"a", "b", "c"}, []string{"a", "c"}, []match{{0, 0}, {2, 1}}},
+		{"shift", []string{"a", "b", "c"}, []string{"b", "c", "d"}, []match{{1, 0}, {2, 1}}},
+		{"middle", []string{"x", "a", "b", "y"}, []string{"z", "a", "b", "w"}, []match{{1, 1}, {2, 2}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, lcs(tc.a, tc.b))
+		})
+	}
+}
+
+func TestLCSLength(t *testing.T) {
+	// abcabba / cbabac has a longest common subsequence of length 4
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+	matches := lcs(a, b)
+	assert.Equal(t, 4, len(matches))
+	for i, m := range matches {
+		assert.Equal(t, a[m.a], b[m.b])
+		if i > 0 {
+			assert.True(t, m.a > matches[i-1].a && m.b > matches[i-1].b)
+		}
+	}
+}
+
+func TestArrayInsertMiddle(t *testing.T) {
+	original := make([]int, 500)
+	for i := range original {
+		original[i] = i
+	}
+	modified := append(append(append([]int{}, original[:250]...), -1), original[250:]...)
+	a, _ := json.Marshal(map[string]interface{}{"items": original})
+	b, _ := json.Marshal(map[string]interface{}{"items": modified})
+
+	patch, err := CreatePatch(a, b)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(patch))
+	assert.Equal(t, "add", patch[0].Op)
+	assert.Equal(t, "/items/250", patch[0].Path)
+	assert.Equal(t, json.Number("-1"), patch[0].Value)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestArrayRemoveMiddle(t *testing.T) {
+	patch, err := CreatePatch([]byte(`[1,2,3,4,5]`), []byte(`[1,2,5]`))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "remove", patch[0].Op)
+	assert.Equal(t, "/3", patch[0].Path)
+	assert.Equal(t, "remove", patch[1].Op)
+	assert.Equal(t, "/2", patch[1].Path)
+}
+
+func TestArrayTrailingAppend(t *testing.T) {
+	patch, err := CreatePatch([]byte(`{"a":[1,2]}`), []byte(`{"a":[1,2,3,4]}`))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	for i, v := range []string{"3", "4"} {
+		assert.Equal(t, "add", patch[i].Op)
+		assert.Equal(t, "/a/-", patch[i].Path)
+		assert.Equal(t, json.Number(v), patch[i].Value)
+	}
+}
+
+func TestArrayReplaceInPlace(t *testing.T) {
+	patch, err := CreatePatch([]byte(`{"a":[1,{"b":1},3]}`), []byte(`{"a":[1,{"b":2},3,4]}`))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "replace", patch[0].Op)
+	assert.Equal(t, "/a/1/b", patch[0].Path)
+	assert.Equal(t, "add", patch[1].Op)
+	assert.Equal(t, "/a/-", patch[1].Path)
+}
+
+func TestTopLevelPrimitiveArray(t *testing.T) {
+	a := []byte(`[1,"two",true,"four"]`)
+	b := []byte(`[0,1,"two",false,"four"]`)
+	patch, err := CreatePatch(a, b)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "add", patch[0].Op)
+	assert.Equal(t, "/0", patch[0].Path)
+	assert.Equal(t, "replace", patch[1].Op)
+	assert.Equal(t, "/3", patch[1].Path)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestArrayRandomRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomArray := func() []interface{} {
+		ary := make([]interface{}, r.Intn(12))
+		for i := range ary {
+			switch r.Intn(3) {
+			case 0:
+				ary[i] = r.Intn(4)
+			case 1:
+				ary[i] = map[string]interface{}{"v": r.Intn(3)}
+			default:
+				ary[i] = []interface{}{r.Intn(3)}
+			}
+		}
+		return ary
+	}
+
+	for i := 0; i < 500; i++ {
+		a, _ := json.Marshal(map[string]interface{}{"items": randomArray()})
+		b, _ := json.Marshal(map[string]interface{}{"items": randomArray()})
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			patch, err := CreatePatch(a, b)
+			require.NoError(t, err)
+			result := applyOperations(t, a, patch)
+			assert.True(t, Equal(b, result), "expected %s got %s", b, result)
+		})
+	}
+}
@@ -24,7 +24,7 @@ func TestComplexOneStringReplaceInArray(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, 1, len(patch), "they should be equal")
 	change := patch[0]
-	assert.Equal(t, "replace", change.Operation, "they should be equal")
+	assert.Equal(t, "replace", change.Op, "they should be equal")
 	assert.Equal(t, "/b/0/c1", change.Path, "they should be equal")
 	assert.Equal(t, "goodbye", change.Value, "they should be equal")
 }
@@ -34,7 +34,7 @@ func TestComplexOneIntReplace(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, 1, len(patch), "they should be equal")
 	change := patch[0]
-	assert.Equal(t, "replace", change.Operation, "they should be equal")
+	assert.Equal(t, "replace", change.Op, "they should be equal")
 	assert.Equal(t, "/e/f", change.Path, "they should be equal")
 	var expected = json.Number("100")
 	assert.Equal(t, expected, change.Value, "they should be equal")
@@ -45,7 +45,7 @@ func TestComplexOneAdd(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, 1, len(patch), "they should be equal")
 	change := patch[0]
-	assert.Equal(t, "add", change.Operation, "they should be equal")
+	assert.Equal(t, "add", change.Op, "they should be equal")
 	assert.Equal(t, "/k", change.Path, "they should be equal")
 	a := make(map[string]interface{})
 	b := make(map[string]interface{})
@@ -60,7 +60,7 @@ func TestComplexOneAddToArray(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, 1, len(patch), "they should be equal")
 	change := patch[0]
-	assert.Equal(t, "add", change.Operation, "they should be equal")
+	assert.Equal(t, "add", change.Op, "they should be equal")
 	assert.Equal(t, "/k", change.Path, "they should be equal")
 	a := make(map[string]interface{})
 	b := make(map[string]interface{})
@@ -76,14 +76,14 @@ func TestComplexVsEmpty(t *testing.T) {
 	assert.Equal(t, 3, len(patch), "they should be equal")
 	sort.Sort(ByPath(patch))
 	change := patch[0]
-	assert.Equal(t, "remove", change.Operation, "they should be equal")
+	assert.Equal(t, "remove", change.Op, "they should be equal")
 	assert.Equal(t, "/a", change.Path, "they should be equal")
 
 	change = patch[1]
-	assert.Equal(t, "remove", change.Operation, "they should be equal")
+	assert.Equal(t, "remove", change.Op, "they should be equal")
 	assert.Equal(t, "/b", change.Path, "they should be equal")
 
 	change = patch[2]
-	assert.Equal(t, "remove", change.Operation, "they should be equal")
+	assert.Equal(t, "remove", change.Op, "they should be equal")
 	assert.Equal(t, "/e", change.Path, "they should be equal")
 }
@@ -24,7 +24,7 @@ func TestCreatePatchEmptyToNonEmpty(t *testing.T) {
 	patch, err := CreatePatch([]byte(`{}`), []byte(`{"a":1}`))
 	require.NoError(t, err)
 	assert.Equal(t, 1, len(patch))
-	assert.Equal(t, "add", patch[0].Operation)
+	assert.Equal(t, "add", patch[0].Op)
 	assert.Equal(t, "/a", patch[0].Path)
 }
 
@@ -32,7 +32,7 @@ func TestCreatePatchNonEmptyToEmpty(t *testing.T) {
 	patch, err := CreatePatch([]byte(`{"a":1}`), []byte(`{}`))
 	require.NoError(t, err)
 	assert.Equal(t, 1, len(patch))
-	assert.Equal(t, "remove", patch[0].Operation)
+	assert.Equal(t, "remove", patch[0].Op)
 	assert.Equal(t, "/a", patch[0].Path)
 }
 
@@ -40,7 +40,7 @@ func TestCreatePatchNullValue(t *testing.T) {
 	patch, err := CreatePatch([]byte(`{"a":1}`), []byte(`{"a":null}`))
 	require.NoError(t, err)
 	assert.Equal(t, 1, len(patch))
-	assert.Equal(t, "replace", patch[0].Operation)
+	assert.Equal(t, "replace", patch[0].Op)
 	assert.Nil(t, patch[0].Value)
 }
 
@@ -48,14 +48,14 @@ func TestCreatePatchNullToValue(t *testing.T) {
 	patch, err := CreatePatch([]byte(`{"a":null}`), []byte(`{"a":1}`))
 	require.NoError(t, err)
 	assert.Equal(t, 1, len(patch))
-	assert.Equal(t, "replace", patch[0].Operation)
+	assert.Equal(t, "replace", patch[0].Op)
 }
 
 func TestCreatePatchNestedNull(t *testing.T) {
 	patch, err := CreatePatch([]byte(`{"a":{"b":null}}`), []byte(`{"a":{"b":1}}`))
 	require.NoError(t, err)
 	assert.Equal(t, 1, len(patch))
-	assert.Equal(t, "replace", patch[0].Operation)
+	assert.Equal(t, "replace", patch[0].Op)
 	assert.Equal(t, "/a/b", patch[0].Path)
 }
 
@@ -94,7 +94,7 @@ func TestCreatePatchBooleanValues(t *testing.T) {
 	patch, err := CreatePatch([]byte(`{"a":true}`), []byte(`{"a":false}`))
 	require.NoError(t, err)
 	assert.Equal(t, 1, len(patch))
-	assert.Equal(t, "replace", patch[0].Operation)
+	assert.Equal(t, "replace", patch[0].Op)
 	assert.Equal(t, false, patch[0].Value)
 }
 
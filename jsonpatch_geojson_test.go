@@ -18,15 +18,15 @@ func TestPointLineStringReplace(t *testing.T) {
 	assert.Equal(t, len(patch), 3, "they should be equal")
 	sort.Sort(ByPath(patch))
 	change := patch[0]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/coordinates/0", "they should be equal")
 	assert.Equal(t, change.Value, []interface{}{json.Number("0.0"), json.Number("1.0")}, "they should be equal")
 	change = patch[1]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/coordinates/1", "they should be equal")
 	assert.Equal(t, change.Value, []interface{}{json.Number("2.0"), json.Number("3.0")}, "they should be equal")
 	change = patch[2]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/type", "they should be equal")
 	assert.Equal(t, change.Value, "LineString", "they should be equal")
 }
@@ -37,15 +37,15 @@ func TestLineStringPointReplace(t *testing.T) {
 	assert.Equal(t, len(patch), 3, "they should be equal")
 	sort.Sort(ByPath(patch))
 	change := patch[0]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/coordinates/0", "they should be equal")
 	assert.Equal(t, change.Value, json.Number("0.0"), "they should be equal")
 	change = patch[1]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/coordinates/1", "they should be equal")
 	assert.Equal(t, change.Value, json.Number("1.0"), "they should be equal")
 	change = patch[2]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/type", "they should be equal")
 	assert.Equal(t, change.Value, "Point", "they should be equal")
 }
@@ -167,15 +167,15 @@ func TestHyperComplexBoolReplace(t *testing.T) {
 	sort.Sort(ByPath(patch))
 
 	change := patch[0]
-	assert.Equal(t, "replace", change.Operation, "they should be equal")
+	assert.Equal(t, "replace", change.Op, "they should be equal")
 	assert.Equal(t, "/goods/0/batters/batter/2/type", change.Path, "they should be equal")
 	assert.Equal(t, "Strawberry", change.Value, "they should be equal")
 	change = patch[1]
-	assert.Equal(t, "add", change.Operation, "they should be equal")
-	assert.Equal(t, "/goods/2/batters/batter/2", change.Path, "they should be equal")
+	assert.Equal(t, "add", change.Op, "they should be equal")
+	assert.Equal(t, "/goods/2/batters/batter/-", change.Path, "they should be equal")
 	assert.Equal(t, map[string]interface{}{"id": "1003", "type": "Vanilla"}, change.Value, "they should be equal")
 	change = patch[2]
-	assert.Equal(t, change.Operation, "remove", "they should be equal")
+	assert.Equal(t, change.Op, "remove", "they should be equal")
 	assert.Equal(t, change.Path, "/goods/2/topping/2", "they should be equal")
 	assert.Equal(t, nil, change.Value, "they should be equal")
 }
@@ -1,32 +1,105 @@
 package jsonpatch
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMarshalNullableValue(t *testing.T) {
 	p1 := Operation{
-		Operation: "replace",
-		Path:      "/a1",
-		Value:     nil,
+		Op:    "replace",
+		Path:  "/a1",
+		Value: nil,
 	}
 	assert.JSONEq(t, `{"op":"replace", "path":"/a1","value":null}`, p1.JSON())
 
 	p2 := Operation{
-		Operation: "replace",
-		Path:      "/a2",
-		Value:     "v2",
+		Op:    "replace",
+		Path:  "/a2",
+		Value: "v2",
 	}
 	assert.JSONEq(t, `{"op":"replace", "path":"/a2", "value":"v2"}`, p2.JSON())
 }
 
 func TestMarshalNonNullableValue(t *testing.T) {
 	p1 := Operation{
-		Operation: "remove",
-		Path:      "/a1",
+		Op:   "remove",
+		Path: "/a1",
 	}
 	assert.JSONEq(t, `{"op":"remove", "path":"/a1"}`, p1.JSON())
+}
+
+func TestMarshalEscaping(t *testing.T) {
+	p := Operation{Op: "move", From: `/a"b`, Path: "/c\\d\n"}
+	assert.JSONEq(t, `{"op":"move","from":"/a\"b","path":"/c\\d\n"}`, p.JSON())
+}
+
+func TestOperationRoundTrip(t *testing.T) {
+	patch := `[{"op":"add","path":"/a~1b","value":{"c":[1,2.50,null]}},{"op":"test","path":"/d","value":null},{"op":"remove","path":"/e"},{"op":"move","path":"/g","from":"/f"},{"op":"copy","path":"/h","from":"/g"},{"op":"replace","path":"","value":12345678901234567890}]`
+
+	var p Patch
+	require.NoError(t, json.Unmarshal([]byte(patch), &p))
+	assert.Equal(t, "/f", p[3].From)
+	assert.Equal(t, json.RawMessage("null"), p[1].Value)
+	assert.Nil(t, p[2].Value)
+
+	out, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.Equal(t, patch, string(out))
+
+	// A missing member is left empty, never applies, and stays missing.
+	for _, member := range []string{`{"op":"copy","path":"/a"}`, `{"op":"add","value":1}`, `{"path":"/a"}`} {
+		var op Operation
+		require.NoError(t, json.Unmarshal([]byte(member), &op))
+		_, err := Patch{op}.Apply([]byte(`{"a":1}`))
+		assert.True(t, errors.Is(err, ErrMissingMember), "%s: %v", member, err)
+		out, err := json.Marshal(op)
+		require.NoError(t, err)
+		assert.Equal(t, member, string(out))
+	}
+
+	var op Operation
+	require.NoError(t, json.Unmarshal([]byte(`{"op":"copy","path":"/a"}`), &op))
+	assert.Equal(t, "", op.From)
+}
+
+// Member names are case sensitive, the other members are ignored.
+func TestOperationMemberNames(t *testing.T) {
+	_, err := DecodePatch([]byte(`[{"OP":"add","Path":"/a","VALUE":1}]`))
+	assert.True(t, errors.Is(err, ErrMissingMember), "%v", err)
+
+	var p Patch
+	require.NoError(t, json.Unmarshal([]byte(`[{"OP":"add","Path":"/a","VALUE":1}]`), &p))
+	_, err = p.Apply([]byte(`{}`))
+	assert.True(t, errors.Is(err, ErrMissingMember), "%v", err)
+
+	p, err = DecodePatch([]byte(`[{"op":"add","path":"/a","value":1,"Value":2,"Path":"/b"}]`))
+	require.NoError(t, err)
+	options := NewApplyOptions()
+	options.Strict = true
+	out, err := p.ApplyWithOptions([]byte(`{}`), options)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(out))
+}
+
+func TestCreatePatchApply(t *testing.T) {
+	a, b := []byte(`{"a":[1,2,3],"b":{"c":"d"}}`), []byte(`{"a":[1,3,4],"b":{"c":"e","f":[2]}}`)
+	ops, err := CreatePatchWithOptions(a, b, DiffOptions{DetectMovesAndCopies: true})
+	require.NoError(t, err)
+
+	out, err := ops.Apply(a)
+	require.NoError(t, err)
+	assert.True(t, Equal(b, out), "got %s", out)
+
+	out, err = Patch([]Operation{NewPatch("replace", "/b/c", map[string]interface{}{"g": 1})}).Apply(a)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":[1,2,3],"b":{"c":{"g":1}}}`, string(out))
 
+	_, err = Patch{NewPatch("add", "/x", func() {})}.Apply(a)
+	var perr *PatchError
+	assert.True(t, errors.As(err, &perr))
 }
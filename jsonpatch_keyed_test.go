@@ -0,0 +1,117 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedArrayEditWithLengthChange(t *testing.T) {
+	opts := DiffOptions{ArrayKeys: map[string]string{"/items": "id"}}
+	a := []byte(`{"items":[{"id":1,"n":"a"},{"id":2,"n":"b"},{"id":3,"n":"c"},{"id":4,"n":"d"}]}`)
+	b := []byte(`{"items":[{"id":1,"n":"a"},{"id":3,"n":"c"},{"id":4,"n":"D"}]}`)
+	patch, err := CreatePatchWithOptions(a, b, opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "remove", patch[0].Op)
+	assert.Equal(t, "/items/1", patch[0].Path)
+	assert.Equal(t, "replace", patch[1].Op)
+	assert.Equal(t, "/items/2/n", patch[1].Path)
+	assert.Equal(t, "D", patch[1].Value)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestKeyedArrayMove(t *testing.T) {
+	opts := DiffOptions{ArrayKeys: map[string]string{"/items": "id"}}
+	a := []byte(`{"items":[{"id":1},{"id":2},{"id":3},{"id":4}]}`)
+	b := []byte(`{"items":[{"id":2},{"id":3},{"id":4},{"id":1,"moved":true}]}`)
+	patch, err := CreatePatchWithOptions(a, b, opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "move", patch[0].Op)
+	assert.Equal(t, "/items/0", patch[0].From)
+	assert.Equal(t, "/items/3", patch[0].Path)
+	assert.Equal(t, "add", patch[1].Op)
+	assert.Equal(t, "/items/3/moved", patch[1].Path)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestKeyedArrayAdd(t *testing.T) {
+	opts := DiffOptions{DefaultArrayKey: "id"}
+	a := []byte(`[{"id":"x"},{"id":"y"}]`)
+	b := []byte(`[{"id":"w"},{"id":"x"},{"id":"y"},{"id":"z"}]`)
+	patch, err := CreatePatchWithOptions(a, b, opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "add", patch[0].Op)
+	assert.Equal(t, "/0", patch[0].Path)
+	assert.Equal(t, "add", patch[1].Op)
+	assert.Equal(t, "/-", patch[1].Path)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestKeyedArrayWildcard(t *testing.T) {
+	opts := DiffOptions{ArrayKeys: map[string]string{"/goods/*/topping": "id"}}
+	patch, err := CreatePatchWithOptions([]byte(hyperComplexBase), []byte(hyperComplexA), opts)
+	require.NoError(t, err)
+	assert.True(t, Equal([]byte(hyperComplexA), applyOperations(t, []byte(hyperComplexBase), patch)))
+
+	a := []byte(`{"goods":[{"topping":[{"id":1,"t":"a"},{"id":2,"t":"b"}]}]}`)
+	b := []byte(`{"goods":[{"topping":[{"id":2,"t":"b"},{"id":1,"t":"c"}]}]}`)
+	patch, err = CreatePatchWithOptions(a, b, opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "move", patch[0].Op)
+	assert.Equal(t, "/goods/0/topping/1/t", patch[1].Path)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestKeyedArrayFallback(t *testing.T) {
+	opts := DiffOptions{DefaultArrayKey: "id"}
+	// Duplicated and missing identifiers are diffed by value.
+	for _, c := range [][2]string{
+		{`[{"id":1},{"id":1}]`, `[{"id":1},{"id":2}]`},
+		{`[{"id":1},{"name":"x"}]`, `[{"name":"x"},{"id":1}]`},
+		{`[1,2,3]`, `[3,2,1]`},
+	} {
+		patch, err := CreatePatchWithOptions([]byte(c[0]), []byte(c[1]), opts)
+		require.NoError(t, err)
+		assert.True(t, Equal([]byte(c[1]), applyOperations(t, []byte(c[0]), patch)))
+	}
+}
+
+func TestMatchPointer(t *testing.T) {
+	assert.True(t, matchPointer("/items", "/items"))
+	assert.True(t, matchPointer("/goods/*/topping", "/goods/2/topping"))
+	assert.True(t, matchPointer("*", ""))
+	assert.False(t, matchPointer("/goods/*/topping", "/goods/2/batters"))
+	assert.False(t, matchPointer("/goods/*", "/goods/2/topping"))
+}
+
+func TestKeyedArrayRandomRoundTrip(t *testing.T) {
+	opts := DiffOptions{DetectMovesAndCopies: true, ArrayKeys: map[string]string{"/items": "id"}}
+	r := rand.New(rand.NewSource(1))
+	randomItems := func() []interface{} {
+		ids := r.Perm(10)[:r.Intn(10)]
+		items := make([]interface{}, len(ids))
+		for i, id := range ids {
+			items[i] = map[string]interface{}{"id": id, "v": r.Intn(2)}
+		}
+		return items
+	}
+
+	for i := 0; i < 500; i++ {
+		a, _ := json.Marshal(map[string]interface{}{"items": randomItems()})
+		b, _ := json.Marshal(map[string]interface{}{"items": randomItems()})
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			patch, err := CreatePatchWithOptions(a, b, opts)
+			require.NoError(t, err)
+			result := applyOperations(t, a, patch)
+			assert.True(t, Equal(b, result), "expected %s got %s", b, result)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var movesOptions = DiffOptions{DetectMovesAndCopies: true}
+
+var movesBase = `{"user":{"name":"John","address":{"street":"Main St","number":42,"city":"Springfield"}},"archive":{}}`
+
+func TestMoveDetectionDisabled(t *testing.T) {
+	patch, err := CreatePatch([]byte(`{"a":{"b":[1,2,3]}}`), []byte(`{"c":{"b":[1,2,3]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(patch))
+	for _, op := range patch {
+		assert.NotEqual(t, "move", op.Op)
+	}
+}
+
+func TestMoveRenamedKey(t *testing.T) {
+	a := []byte(`{"a":{"b":[1,2,3]},"d":1}`)
+	b := []byte(`{"c":{"b":[1,2,3]},"d":1}`)
+	patch, err := CreatePatchWithOptions(a, b, movesOptions)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(patch))
+	assert.Equal(t, "move", patch[0].Op)
+	assert.Equal(t, "/a", patch[0].From)
+	assert.Equal(t, "/c", patch[0].Path)
+	assert.Nil(t, patch[0].Value)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestMoveRelocatedSubtree(t *testing.T) {
+	a := []byte(movesBase)
+	b := []byte(`{"user":{"name":"John"},"archive":{"address":{"street":"Main St","number":42,"city":"Springfield"}}}`)
+	patch, err := CreatePatchWithOptions(a, b, movesOptions)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(patch))
+	assert.Equal(t, "move", patch[0].Op)
+	assert.Equal(t, "/user/address", patch[0].From)
+	assert.Equal(t, "/archive/address", patch[0].Path)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestMoveIntoArray(t *testing.T) {
+	a := []byte(`{"list":[{"id":1}],"pending":{"item":{"id":2,"tags":["a","b"]}}}`)
+	b := []byte(`{"list":[{"id":1},{"id":2,"tags":["a","b"]}],"pending":{}}`)
+	patch, err := CreatePatchWithOptions(a, b, movesOptions)
+	require.NoError(t, err)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestMoveInsideArrayElement(t *testing.T) {
+	a := []byte(`{"list":[{"id":1,"old":{"x":1}},{"id":2}]}`)
+	b := []byte(`{"list":[{"id":0},{"id":1,"new":{"x":1}},{"id":3}]}`)
+	patch, err := CreatePatchWithOptions(a, b, movesOptions)
+	require.NoError(t, err)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestCopyDetection(t *testing.T) {
+	a := []byte(movesBase)
+	b := []byte(`{"user":{"name":"John","address":{"street":"Main St","number":42,"city":"Springfield"}},"archive":{"billing":{"street":"Main St","number":42,"city":"Springfield"}}}`)
+	patch, err := CreatePatchWithOptions(a, b, movesOptions)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(patch))
+	assert.Equal(t, "copy", patch[0].Op)
+	assert.Equal(t, "/user/address", patch[0].From)
+	assert.Equal(t, "/archive/billing", patch[0].Path)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
+
+func TestCopySkipsShortValues(t *testing.T) {
+	patch, err := CreatePatchWithOptions([]byte(`{"aVeryLongKey":1}`), []byte(`{"aVeryLongKey":1,"b":1}`), movesOptions)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(patch))
+	assert.Equal(t, "add", patch[0].Op)
+}
+
+func TestMoveRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name     string
+		original string
+		modified string
+	}{
+		{"simple", simpleA, simpleD},
+		{"complex", complexBase, complexC},
+		{"hypercomplex", hyperComplexBase, hyperComplexA},
+		{"supercomplex", superComplexBase, superComplexA},
+		{"swap", `{"a":{"x":1},"b":{"y":2}}`, `{"a":{"y":2},"b":{"x":1}}`},
+		{"subarray", subArray5_current, subArray5__target},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := CreatePatchWithOptions([]byte(tc.original), []byte(tc.modified), movesOptions)
+			require.NoError(t, err)
+			result := applyOperations(t, []byte(tc.original), patch)
+			assert.True(t, Equal([]byte(tc.modified), result), "expected %s got %s", tc.modified, result)
+		})
+	}
+}
+
+// The state used to detect moves is not kept in the patch.
+func TestMoveDetectionState(t *testing.T) {
+	a := []byte(`{"a":{"b":[1,2,3]},"d":{"e":1},"f":[{"g":1}]}`)
+	b := []byte(`{"c":{"b":[1,2,3]},"f":[]}`)
+
+	for _, opts := range []DiffOptions{{}, movesOptions} {
+		patch, err := CreatePatchWithOptions(a, b, opts)
+		require.NoError(t, err)
+		for _, op := range patch {
+			assert.Nil(t, op.old, op.JSON())
+			assert.False(t, op.member, op.JSON())
+		}
+	}
+
+	patch, err := CreatePatchWithOptions(a, b, movesOptions)
+	require.NoError(t, err)
+	assert.Equal(t, Patch{
+		{Op: "move", Path: "/c", From: "/a"},
+		{Op: "remove", Path: "/d"},
+		{Op: "remove", Path: "/f/0"},
+	}, patch)
+}
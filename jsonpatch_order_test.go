@@ -0,0 +1,100 @@
+package jsonpatch
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePatchKeyOrder(t *testing.T) {
+	a := []byte(`{"e":{"z":1,"y":2},"c":1,"a":1}`)
+	b := []byte(`{"d":1,"b":1,"e":{"x":1,"y":3}}`)
+	patch, err := CreatePatch(a, b)
+	require.NoError(t, err)
+	out, err := MarshalPatch(patch)
+	require.NoError(t, err)
+	assert.Equal(t, `[`+
+		`{"op":"remove","path":"/a"},`+
+		`{"op":"add","path":"/b","value":1},`+
+		`{"op":"remove","path":"/c"},`+
+		`{"op":"add","path":"/d","value":1},`+
+		`{"op":"add","path":"/e/x","value":1},`+
+		`{"op":"replace","path":"/e/y","value":3},`+
+		`{"op":"remove","path":"/e/z"}`+
+		`]`, string(out))
+}
+
+func TestCreatePatchReproducible(t *testing.T) {
+	testCases := []struct {
+		name     string
+		original string
+		modified string
+	}{
+		{"simple", simpleA, simplef},
+		{"vs_empty", complexBase, empty},
+		{"complex", complexBase, complexC},
+		{"hypercomplex", hyperComplexBase, hyperComplexA},
+		{"supercomplex", superComplexBase, superComplexA},
+		{"subarray", subArray5_current, subArray5__target},
+		{"moves", movesBase, `{"user":{"name":"Jane"},"archive":{"address":{"street":"Main St","number":42,"city":"Springfield"},"name":"John"}}`},
+	}
+
+	arrayKeys := map[string]string{"/goods/*/topping": "id", "/goods/*": "id"}
+	for _, opts := range []DiffOptions{{ArrayKeys: arrayKeys}, {ArrayKeys: arrayKeys, DetectMovesAndCopies: true}} {
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				patch, err := CreatePatchWithOptions([]byte(tc.original), []byte(tc.modified), opts)
+				require.NoError(t, err)
+				expected, err := MarshalPatch(patch)
+				require.NoError(t, err)
+				for i := 0; i < 50; i++ {
+					patch, err := CreatePatchWithOptions([]byte(tc.original), []byte(tc.modified), opts)
+					require.NoError(t, err)
+					out, err := MarshalPatch(patch)
+					require.NoError(t, err)
+					require.Equal(t, string(expected), string(out))
+				}
+				result := applyOperations(t, []byte(tc.original), patch)
+				assert.True(t, Equal([]byte(tc.modified), result))
+			})
+		}
+	}
+}
+
+func TestCreatePatchWithOptionsConcurrent(t *testing.T) {
+	a := []byte(`{"items":[{"id":1,"v":1},{"id":2,"v":2}],"old":{"x":[1,2,3]}}`)
+	b := []byte(`{"items":[{"id":2,"v":2},{"id":1,"v":3}],"new":{"x":[1,2,3]}}`)
+	options := []DiffOptions{
+		{},
+		{DetectMovesAndCopies: true},
+		{ArrayKeys: map[string]string{"/items": "id"}},
+		{DetectMovesAndCopies: true, DefaultArrayKey: "id"},
+	}
+	expected := make([]string, len(options))
+	for i, opts := range options {
+		patch, err := CreatePatchWithOptions(a, b, opts)
+		require.NoError(t, err)
+		out, err := MarshalPatch(patch)
+		require.NoError(t, err)
+		expected[i] = string(out)
+		assert.True(t, Equal(b, applyOperations(t, a, patch)))
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 100)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			patch, _ := CreatePatchWithOptions(a, b, options[i%len(options)])
+			out, _ := MarshalPatch(patch)
+			results[i] = string(out)
+		}(i)
+	}
+	wg.Wait()
+	for i, out := range results {
+		assert.Equal(t, expected[i%len(options)], out)
+	}
+}
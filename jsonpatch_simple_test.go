@@ -84,42 +84,37 @@ func TestCollectionWindowAscMove(t *testing.T) {
 	patch, e := CreatePatch([]byte(collectionWindowAscBefore), []byte(collectionWindowAscAfter))
 	assert.NoError(t, e)
 	assert.Equal(t, 2, len(patch), "the patch should have one add and one remove")
-	assert.Equal(t, "remove", patch[0].Operation, "the patch should remove on 0")
+	assert.Equal(t, "remove", patch[0].Op, "the patch should remove on 0")
 	assert.Equal(t, "/0", patch[0].Path, "the patch should remove on 0")
-	assert.Equal(t, "add", patch[1].Operation, "the patch should add on the last position")
+	assert.Equal(t, "add", patch[1].Op, "the patch should add on the last position")
 	newEntry, err := json.Marshal(&patch[1].Value)
 	require.NoError(t, err)
 	assert.Equal(t, `{"test":"4"}`, string(newEntry))
-	assert.Equal(t, "/2", patch[1].Path, "the patch should add on the last position")
+	assert.Equal(t, "/-", patch[1].Path, "the patch should append on the last position")
 }
 
 func TestCollectionWindowDscMove(t *testing.T) {
 	patch, e := CreatePatch([]byte(collectionWindowDscBefore), []byte(collectionWindowDscAfter))
 	assert.NoError(t, e)
 	assert.Equal(t, 2, len(patch), "the patch should have one add and one remove")
-	assert.Equal(t, "add", patch[0].Operation, "the patch should add on 0")
+	assert.Equal(t, "add", patch[0].Op, "the patch should add on 0")
 	assert.Equal(t, "/0", patch[0].Path, "the patch should add on 0")
 	newEntry, err := json.Marshal(&patch[0].Value)
 	require.NoError(t, err)
 	assert.Equal(t, `{"test":"4"}`, string(newEntry))
-	assert.Equal(t, "remove", patch[1].Operation, "the patch should remove last position")
+	assert.Equal(t, "remove", patch[1].Op, "the patch should remove last position")
 	assert.Equal(t, "/3", patch[1].Path, "the patch should have descending order by path")
 }
 
 func TestCollectionAdd(t *testing.T) {
 	patch, e := CreatePatch([]byte(collectionOne), []byte(collectionTwo))
 	assert.NoError(t, e)
-	assert.Equal(t, 2, len(patch), "the patch should have one add")
-	assert.Equal(t, "replace", patch[0].Operation, "the patch should add on 0")
-	assert.Equal(t, "/0/test", patch[0].Path, "the patch should add on 0")
-	assert.Equal(t, "add", patch[1].Operation, "the patch should add on 1")
-	assert.Equal(t, "/1", patch[1].Path, "the patch should add on 1")
-	newEntry, err := json.Marshal(&patch[1].Value)
-	require.NoError(t, err)
-	assert.Equal(t, `{"test":"1"}`, string(newEntry))
-	newEntry, err = json.Marshal(&patch[0].Value)
+	assert.Equal(t, 1, len(patch), "the patch should have one add")
+	assert.Equal(t, "add", patch[0].Op, "the patch should add on 0")
+	assert.Equal(t, "/0", patch[0].Path, "the patch should add on 0")
+	newEntry, err := json.Marshal(&patch[0].Value)
 	require.NoError(t, err)
-	assert.Equal(t, `"2"`, string(newEntry))
+	assert.Equal(t, `{"test":"2"}`, string(newEntry))
 }
 
 func TestOneNullReplace(t *testing.T) {
@@ -127,7 +122,7 @@ func TestOneNullReplace(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, len(patch), 1, "they should be equal")
 	change := patch[0]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/b", "they should be equal")
 	assert.Equal(t, change.Value, nil, "they should be equal")
 }
@@ -143,7 +138,7 @@ func TestOneStringReplace(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, len(patch), 1, "they should be equal")
 	change := patch[0]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/c", "they should be equal")
 	assert.Equal(t, change.Value, "goodbye", "they should be equal")
 }
@@ -153,7 +148,7 @@ func TestOneIntReplace(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, len(patch), 1, "they should be equal")
 	change := patch[0]
-	assert.Equal(t, change.Operation, "replace", "they should be equal")
+	assert.Equal(t, change.Op, "replace", "they should be equal")
 	assert.Equal(t, change.Path, "/b", "they should be equal")
 	var expected = json.Number("100")
 	assert.Equal(t, change.Value, expected, "they should be equal")
@@ -164,7 +159,7 @@ func TestOneAdd(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, len(patch), 1, "they should be equal")
 	change := patch[0]
-	assert.Equal(t, change.Operation, "add", "they should be equal")
+	assert.Equal(t, change.Op, "add", "they should be equal")
 	assert.Equal(t, change.Path, "/d", "they should be equal")
 	assert.Equal(t, change.Value, "foo", "they should be equal")
 }
@@ -174,7 +169,7 @@ func TestOneRemove(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, len(patch), 1, "they should be equal")
 	change := patch[0]
-	assert.Equal(t, change.Operation, "remove", "they should be equal")
+	assert.Equal(t, change.Op, "remove", "they should be equal")
 	assert.Equal(t, change.Path, "/c", "they should be equal")
 	assert.Equal(t, change.Value, nil, "they should be equal")
 }
@@ -185,15 +180,15 @@ func TestVsEmpty(t *testing.T) {
 	assert.Equal(t, len(patch), 3, "they should be equal")
 	sort.Sort(ByPath(patch))
 	change := patch[0]
-	assert.Equal(t, change.Operation, "remove", "they should be equal")
+	assert.Equal(t, change.Op, "remove", "they should be equal")
 	assert.Equal(t, change.Path, "/a", "they should be equal")
 
 	change = patch[1]
-	assert.Equal(t, change.Operation, "remove", "they should be equal")
+	assert.Equal(t, change.Op, "remove", "they should be equal")
 	assert.Equal(t, change.Path, "/b", "they should be equal")
 
 	change = patch[2]
-	assert.Equal(t, change.Operation, "remove", "they should be equal")
+	assert.Equal(t, change.Op, "remove", "they should be equal")
 	assert.Equal(t, change.Path, "/c", "they should be equal")
 }
 
@@ -207,7 +202,7 @@ func BenchmarkBigArrays(b *testing.B) {
 		a2[i+1] = i
 	}
 	for i := 0; i < b.N; i++ {
-		compareArray(a1, a2, "/")
+		compareArray(a1, a2, "", []Operation{}, &DiffOptions{})
 	}
 }
 
@@ -221,6 +216,6 @@ func BenchmarkBigArrays2(b *testing.B) {
 		a2[i] = i
 	}
 	for i := 0; i < b.N; i++ {
-		compareArray(a1, a2, "/")
+		compareArray(a1, a2, "", []Operation{}, &DiffOptions{})
 	}
 }
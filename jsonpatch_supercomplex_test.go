@@ -500,7 +500,7 @@ func TestSuperComplexBoolReplace(t *testing.T) {
 	assert.NoError(t, e)
 	assert.Equal(t, 1, len(patch), "they should be equal")
 	change := patch[0]
-	assert.Equal(t, "replace", change.Operation, "they should be equal")
+	assert.Equal(t, "replace", change.Op, "they should be equal")
 	assert.Equal(t, "/attributes/attribute-key/36/properties/visible", change.Path, "they should be equal")
 	assert.Equal(t, false, change.Value, "they should be equal")
 }
@@ -0,0 +1,134 @@
+package jsonpatch
+
+// match pairs the index of an element in the original array with the index
+// of the same element in the modified array.
+type match struct {
+	a, b int
+}
+
+// lcs returns the longest common subsequence of `a` and `b` as a list of
+// matching index pairs in ascending order.
+//
+// It implements the linear space variant of Myers' O(ND) difference algorithm
+// (http://www.xmailserver.org/diff2.pdf), so the cost of a small edit stays
+// small even on long arrays.
+func lcs(a, b []string) []match {
+	return lcsRange(a, b, 0, 0, []match{})
+}
+
+func lcsRange(a, b []string, aOff, bOff int, matches []match) []match {
+	// Common prefix.
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		matches = append(matches, match{aOff, bOff})
+		a, b = a[1:], b[1:]
+		aOff++
+		bOff++
+	}
+	// Common suffix, appended after the middle part.
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if len(a) > 0 && len(b) > 0 {
+		if x, y, ok := middleSnake(a, b); ok {
+			matches = lcsRange(a[:x], b[:y], aOff, bOff, matches)
+			matches = lcsRange(a[x:], b[y:], aOff+x, bOff+y, matches)
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		matches = append(matches, match{aOff + len(a) + i, bOff + len(b) + i})
+	}
+	return matches
+}
+
+// middleSnake finds the point where the forward and reverse searches of the
+// shortest edit script overlap, which splits the problem in two halves.
+// ok is false when `a` and `b` have nothing in common.
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	vOffset := maxD
+	vLength := 2*maxD + 2
+	v1 := make([]int, vLength)
+	v2 := make([]int, vLength)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[vOffset+1] = 0
+	v2[vOffset+1] = 0
+	delta := n - m
+	// If the total number of elements is odd, the front path collides with
+	// the reverse path.
+	front := delta%2 != 0
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		// Walk the front path one step.
+		for k1 := -d + k1start; k1 <= d-k1end; k1 += 2 {
+			k1Offset := vOffset + k1
+			var x1 int
+			if k1 == -d || (k1 != d && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+			switch {
+			case x1 > n:
+				// Ran off the right of the graph.
+				k1end += 2
+			case y1 > m:
+				// Ran off the bottom of the graph.
+				k1start += 2
+			case front:
+				k2Offset := vOffset + delta - k1
+				if k2Offset >= 0 && k2Offset < vLength && v2[k2Offset] != -1 {
+					// Mirror x2 onto top-left coordinate system.
+					if x1 >= n-v2[k2Offset] {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+		// Walk the reverse path one step.
+		for k2 := -d + k2start; k2 <= d-k2end; k2 += 2 {
+			k2Offset := vOffset + k2
+			var x2 int
+			if k2 == -d || (k2 != d && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				k1Offset := vOffset + delta - k2
+				if k1Offset >= 0 && k1Offset < vLength && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := vOffset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,95 @@
+package jsonpatch
+
+// Limits bounds the resources used by a patch coming from an untrusted
+// source. A zero field means no limit.
+type Limits struct {
+	// MaxOperations is the number of operations of a patch.
+	MaxOperations int
+	// MaxPointerDepth is the number of tokens of the path and from location
+	// of an operation.
+	MaxPointerDepth int
+	// MaxValueSize is the size in bytes of the encoded value of an operation.
+	MaxValueSize int
+	// MaxDocumentSize is the size in bytes of the encoded patched document.
+	// It is also checked as the operations apply, against the size of the
+	// document plus the values added and copied, so that a patch fails before
+	// growing the document past it.
+	MaxDocumentSize int
+	// MaxArrayLength is the number of elements an array can be grown to by
+	// the "add", "move" and "copy" operations, or hold in the value of an
+	// "add" or "replace", exceeding it fails with an *ArraySizeError.
+	MaxArrayLength int
+}
+
+// checkOperations returns a *LimitError if a patch of n operations exceeds
+// the limits.
+func (l *Limits) checkOperations(n int) error {
+	return l.check("MaxOperations", l.MaxOperations, n)
+}
+
+// checkOperation returns a *LimitError if the locations or the value of op
+// exceed the limits, or an *ArraySizeError if the value holds a too long
+// array.
+func (l *Limits) checkOperation(op *compiledOp) error {
+	if err := l.check("MaxPointerDepth", l.MaxPointerDepth, len(op.path)); err != nil {
+		return err
+	}
+
+	if err := l.check("MaxPointerDepth", l.MaxPointerDepth, len(op.from)); err != nil {
+		return err
+	}
+
+	if err := l.check("MaxValueSize", l.MaxValueSize, len(op.value)); err != nil {
+		return err
+	}
+
+	if l.MaxArrayLength > 0 && (op.Op == "add" || op.Op == "replace") && op.value != nil {
+		v, err := decodeValue(op.value)
+		if err != nil {
+			return err
+		}
+		if n := longestArray(v); n > l.MaxArrayLength {
+			return NewArraySizeError(l.MaxArrayLength, n)
+		}
+	}
+
+	return nil
+}
+
+// longestArray returns the length of the longest array in the decoded value
+// v.
+func longestArray(v interface{}) int {
+	n := 0
+
+	switch v := v.(type) {
+	case []interface{}:
+		n = len(v)
+		for _, elem := range v {
+			if m := longestArray(elem); m > n {
+				n = m
+			}
+		}
+	case map[string]interface{}:
+		for _, elem := range v {
+			if m := longestArray(elem); m > n {
+				n = m
+			}
+		}
+	}
+
+	return n
+}
+
+// checkDocument returns a *LimitError if the encoded document doc exceeds the
+// limits.
+func (l *Limits) checkDocument(doc []byte) error {
+	return l.check("MaxDocumentSize", l.MaxDocumentSize, len(doc))
+}
+
+func (l *Limits) check(name string, max, size int) error {
+	if max > 0 && size > max {
+		return &LimitError{Limit: name, Max: max, Size: size}
+	}
+
+	return nil
+}
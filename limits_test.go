@@ -0,0 +1,185 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePatchWithLimits(t *testing.T) {
+	limits := Limits{MaxOperations: 2, MaxPointerDepth: 2, MaxValueSize: 8}
+
+	_, err := DecodePatchWithLimits([]byte(`[{"op":"add","path":"/a/b","value":"1234"},{"op":"move","from":"/a/b","path":"/c"}]`), limits)
+	assert.NoError(t, err)
+
+	_, err = DecodePatchWithLimits([]byte(`[{"op":"remove","path":"/a"},{"op":"remove","path":"/b"},{"op":"remove","path":"/c"}]`), limits)
+	var lerr *LimitError
+	require.True(t, errors.As(err, &lerr), "%v", err)
+	assert.Equal(t, &LimitError{Limit: "MaxOperations", Max: 2, Size: 3}, lerr)
+
+	testCases := []struct {
+		patch string
+		limit string
+	}{
+		{`[{"op":"remove","path":"/a"},{"op":"remove","path":"/a/b/c"}]`, "MaxPointerDepth"},
+		{`[{"op":"copy","from":"/a/b/c","path":"/a"}]`, "MaxPointerDepth"},
+		{`[{"op":"add","path":"/a","value":"12345678"}]`, "MaxValueSize"},
+		{`[{"op":"test","path":"/a","value":{"a": [1, 2]}}]`, "MaxValueSize"},
+	}
+
+	for _, tc := range testCases {
+		_, err := DecodePatchWithLimits([]byte(tc.patch), limits)
+		var perr *PatchError
+		require.True(t, errors.As(err, &perr), "%s: %v", tc.patch, err)
+		require.True(t, errors.As(err, &lerr), "%s: %v", tc.patch, err)
+		assert.Equal(t, tc.limit, lerr.Limit, tc.patch)
+		assert.True(t, errors.Is(err, ErrLimitExceeded))
+	}
+
+	_, err = DecodePatchWithLimits([]byte(`[{"op":"add","path":"/a","value":{"b":[1,2,3]}}]`), Limits{MaxArrayLength: 3})
+	assert.NoError(t, err)
+	_, err = DecodePatchWithLimits([]byte(`[{"op":"add","path":"/a","value":{"b":[1,2,3,4]}}]`), Limits{MaxArrayLength: 3})
+	var perr *PatchError
+	assert.True(t, errors.As(err, &perr), "%v", err)
+	var serr *ArraySizeError
+	assert.True(t, errors.As(err, &serr), "%v", err)
+
+	// DecodePatch has no limits.
+	_, err = DecodePatch([]byte(`[{"op":"add","path":"/a/b/c/d/e","value":"12345678901234567890"}]`))
+	assert.NoError(t, err)
+}
+
+func TestApplyWithLimits(t *testing.T) {
+	doc := `{"a":[1,2],"b":{"c":{"d":1}}}`
+
+	testCases := []struct {
+		patch  Patch
+		limits Limits
+		limit  string
+	}{
+		{Patch{NewPatch("remove", "/b", nil), NewPatch("remove", "/a", nil)}, Limits{MaxOperations: 1}, "MaxOperations"},
+		{Patch{NewPatch("remove", "/b/c/d", nil)}, Limits{MaxPointerDepth: 2}, "MaxPointerDepth"},
+		{Patch{NewPatch("replace", "/b", "a long string")}, Limits{MaxValueSize: 10}, "MaxValueSize"},
+		{Patch{NewPatch("add", "/e", "a long string")}, Limits{MaxDocumentSize: 40}, "MaxDocumentSize"},
+	}
+
+	for _, tc := range testCases {
+		options := NewApplyOptions()
+		options.Limits = tc.limits
+
+		_, err := tc.patch.ApplyWithOptions([]byte(doc), options)
+		var lerr *LimitError
+		require.True(t, errors.As(err, &lerr), "%v: %v", tc.patch, err)
+		assert.Equal(t, tc.limit, lerr.Limit)
+
+		compiled, err := tc.patch.Compile()
+		require.NoError(t, err)
+		_, err = compiled.ApplyWithOptions([]byte(doc), options)
+		require.True(t, errors.As(err, &lerr), "%v: %v", tc.patch, err)
+		assert.Equal(t, tc.limit, lerr.Limit)
+
+		d, err := NewDocument([]byte(doc))
+		require.NoError(t, err)
+		err = d.ApplyWithOptions(tc.patch, options)
+		require.True(t, errors.As(err, &lerr), "%v: %v", tc.patch, err)
+		assert.Equal(t, tc.limit, lerr.Limit)
+		out, err := d.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, doc, string(out))
+	}
+
+	// Within the limits.
+	options := NewApplyOptions()
+	options.Limits = Limits{MaxOperations: 1, MaxPointerDepth: 3, MaxValueSize: 15, MaxDocumentSize: 49}
+	out, err := Patch{NewPatch("add", "/b/c/e", "a long string")}.ApplyWithOptions([]byte(doc), options)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[1,2],"b":{"c":{"d":1,"e":"a long string"}}}`, string(out))
+}
+
+// Copies fail as soon as the document grows past MaxDocumentSize, before it
+// doubles at each of them.
+func TestApplyWithDocumentSizeLimitCopies(t *testing.T) {
+	doc := []byte(`{"a":"` + strings.Repeat("x", 1000) + `"}`)
+	patch := Patch{}
+	for i := 0; i < 40; i++ {
+		patch = append(patch, Operation{Op: "copy", From: "", Path: fmt.Sprintf("/c%d", i)})
+	}
+
+	options := NewApplyOptions()
+	options.Limits = Limits{MaxOperations: 100, MaxDocumentSize: 10000, MaxValueSize: 2000}
+
+	check := func(err error) {
+		var perr *PatchError
+		require.True(t, errors.As(err, &perr), "%v", err)
+		assert.Equal(t, 3, perr.Index)
+		var lerr *LimitError
+		require.True(t, errors.As(err, &lerr), "%v", err)
+		assert.Equal(t, "MaxDocumentSize", lerr.Limit)
+	}
+
+	_, err := patch.ApplyWithOptions(doc, options)
+	check(err)
+
+	compiled, err := patch.Compile()
+	require.NoError(t, err)
+	_, err = compiled.ApplyWithOptions(doc, options)
+	check(err)
+
+	d, err := NewDocument(doc)
+	require.NoError(t, err)
+	check(d.ApplyWithOptions(patch, options))
+	out, err := d.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, string(doc), string(out))
+
+	// Added values count as well.
+	_, err = Patch{NewPatch("add", "/b", strings.Repeat("x", 1000)), NewPatch("add", "/c", strings.Repeat("x", 1000))}.ApplyWithOptions(doc, &ApplyOptions{Limits: Limits{MaxDocumentSize: 2500}})
+	var perr *PatchError
+	require.True(t, errors.As(err, &perr), "%v", err)
+	assert.Equal(t, 1, perr.Index)
+}
+
+func TestApplyWithArrayLengthLimit(t *testing.T) {
+	options := NewApplyOptions()
+	options.Limits.MaxArrayLength = 3
+	doc := `{"a":[1,2],"b":[3,4,5]}`
+
+	ok := []Patch{
+		{NewPatch("add", "/a/-", 3)},
+		{NewPatch("remove", "/b/0", nil), NewPatch("add", "/b/0", 0)},
+		{NewPatch("remove", "/a/0", nil), NewPatch("replace", "/b/1", []int{1, 2, 3})},
+		{{Op: "move", From: "/b/0", Path: "/a/0"}},
+		{NewPatch("test", "/b", []int{3, 4, 5})},
+	}
+	for _, patch := range ok {
+		_, err := patch.ApplyWithOptions([]byte(doc), options)
+		assert.NoError(t, err, "%v", patch)
+	}
+
+	failing := []Patch{
+		{NewPatch("add", "/b/-", 6)},
+		{NewPatch("add", "/a/0", 0), NewPatch("add", "/a/0", 0)},
+		{{Op: "copy", From: "/a/0", Path: "/b/1"}},
+		{NewPatch("add", "/a", []int{1, 2, 3, 4})},
+		{NewPatch("replace", "", map[string]interface{}{"c": [][]int{{1, 2, 3, 4}}})},
+		{NewPatch("remove", "/a/0", nil), NewPatch("replace", "/b/1", []int{1, 2, 3, 4})},
+	}
+	for _, patch := range failing {
+		_, err := patch.ApplyWithOptions([]byte(doc), options)
+		var serr *ArraySizeError
+		assert.True(t, errors.As(err, &serr), "%v: %v", patch, err)
+		assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+		d, err := NewDocument([]byte(doc))
+		require.NoError(t, err)
+		err = d.ApplyWithOptions(patch, options)
+		assert.True(t, errors.As(err, &serr), "%v: %v", patch, err)
+		out, err := d.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, doc, string(out))
+	}
+}
@@ -0,0 +1,258 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/goccy/go-json"
+)
+
+// JSON Merge Patch, as specified in https://tools.ietf.org/html/rfc7386 :
+//
+// define MergePatch(Target, Patch):
+//   if Patch is an Object:
+//     if Target is not an Object:
+//       Target = {} # Ignore the contents and set it to an empty Object
+//     for each Name/Value pair in Patch:
+//       if Value is null:
+//         if Name exists in Target:
+//           remove the Name/Value pair from Target
+//       else:
+//         Target[Name] = MergePatch(Target[Name], Value)
+//     return Target
+//   else:
+//     return Patch
+
+// MergePatch applies the RFC 7386 merge patch to doc and returns the new
+// document.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := newRootDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	mergePatch, err := newRootDoc(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	pd, ok := asDoc(mergePatch.node)
+	if !ok {
+		return json.Marshal(mergePatch.node)
+	}
+
+	td, ok := asDoc(target.node)
+	if !ok {
+		td = &partialDoc{}
+	}
+
+	mergeDocs(td, pd)
+
+	return json.Marshal(td)
+}
+
+// asDoc returns the object held by n, if any.
+func asDoc(n *lazyNode) (*partialDoc, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	if n.which == eDoc {
+		return &n.doc, true
+	}
+
+	if n.which != eRaw || n.raw == nil || isArray(*n.raw) {
+		return nil, false
+	}
+
+	pd, err := n.intoDoc()
+
+	return pd, err == nil
+}
+
+// mergeDocs applies the members of patch to doc in place.
+func mergeDocs(doc, patch *partialDoc) {
+	for k, v := range *patch {
+		if v == nil {
+			delete(*doc, k)
+			continue
+		}
+
+		pv, ok := asDoc(v)
+		if !ok {
+			(*doc)[k] = v
+			continue
+		}
+
+		cur, ok := asDoc((*doc)[k])
+		if !ok {
+			cur = &partialDoc{}
+		}
+
+		mergeDocs(cur, pv)
+		(*doc)[k] = &lazyNode{doc: *cur, which: eDoc}
+	}
+}
+
+// CreateMergePatch creates the RFC 7386 merge patch turning the original
+// document a into the modified document b. Arrays are replaced as a whole.
+// ErrNotRepresentable is returned when b holds a null object member that is
+// not in a, as null members of a merge patch remove them.
+func CreateMergePatch(a, b []byte) ([]byte, error) {
+	original, err := newRootDoc(a)
+	if err != nil {
+		return nil, err
+	}
+
+	modified, err := newRootDoc(b)
+	if err != nil {
+		return nil, err
+	}
+
+	bd, ok := asDoc(modified.node)
+	if !ok {
+		return json.Marshal(modified.node)
+	}
+
+	ad, ok := asDoc(original.node)
+	if !ok {
+		ad = &partialDoc{}
+	}
+
+	patch, err := createMergeDoc(ad, bd, Pointer{})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(patch)
+}
+
+func createMergeDoc(a, b *partialDoc, path Pointer) (partialDoc, error) {
+	patch := partialDoc{}
+
+	for k := range *a {
+		if _, ok := (*b)[k]; !ok {
+			patch[k] = nil
+		}
+	}
+
+	for k, bv := range *b {
+		av, ok := (*a)[k]
+
+		if bv == nil {
+			if ok && av == nil {
+				continue
+			}
+			return nil, errNotRepresentable(path.Append(k))
+		}
+
+		bd, isDoc := asDoc(bv)
+		if ad, ok := asDoc(av); ok && isDoc {
+			sub, err := createMergeDoc(ad, bd, path.Append(k))
+			if err != nil {
+				return nil, err
+			}
+			if len(sub) > 0 {
+				patch[k] = &lazyNode{doc: sub, which: eDoc}
+			}
+			continue
+		}
+
+		if av != nil && av.equal(bv) {
+			continue
+		}
+
+		if isDoc {
+			// A new object must not hold null members, the merge would drop them.
+			if _, err := createMergeDoc(&partialDoc{}, bd, path.Append(k)); err != nil {
+				return nil, err
+			}
+		}
+
+		patch[k] = bv
+	}
+
+	return patch, nil
+}
+
+func errNotRepresentable(path Pointer) error {
+	return fmt.Errorf("%w: null member at %q", ErrNotRepresentable, path.String())
+}
+
+// MergePatchToPatch converts the merge patch into the RFC 6902 patch having
+// the same effect on doc. Arrays are replaced as a whole.
+func MergePatchToPatch(doc, mergePatch []byte) (Patch, error) {
+	target, err := newRootDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	merge, err := newRootDoc(mergePatch)
+	if err != nil {
+		return nil, err
+	}
+
+	pd, ok := asDoc(merge.node)
+	if !ok {
+		return Patch{newOperation("replace", "", "", merge.node)}, nil
+	}
+
+	td, ok := asDoc(target.node)
+	if !ok {
+		merged := partialDoc{}
+		mergeDocs(&merged, pd)
+		return Patch{newOperation("replace", "", "", &lazyNode{doc: merged, which: eDoc})}, nil
+	}
+
+	return mergeOperations(td, pd, Pointer{}, Patch{}), nil
+}
+
+func mergeOperations(doc, patch *partialDoc, path Pointer, ops Patch) Patch {
+	keys := make([]string, 0, len(*patch))
+	for k := range *patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := (*patch)[k]
+		cur, exists := (*doc)[k]
+
+		if v == nil {
+			if exists {
+				ops = append(ops, newOperation("remove", "", path.Append(k).String(), nil))
+			}
+			continue
+		}
+
+		if pv, ok := asDoc(v); ok {
+			if cd, ok := asDoc(cur); ok {
+				ops = mergeOperations(cd, pv, path.Append(k), ops)
+				continue
+			}
+			merged := partialDoc{}
+			mergeDocs(&merged, pv)
+			v = &lazyNode{doc: merged, which: eDoc}
+		}
+
+		kind := "add"
+		if exists {
+			kind = "replace"
+		}
+		ops = append(ops, newOperation(kind, "", path.Append(k).String(), v))
+	}
+
+	return ops
+}
+
+// PatchToMergePatch converts the RFC 6902 patch into the merge patch having
+// the same effect on doc. ErrNotRepresentable is returned when the patch
+// sets a null object member, which a merge patch cannot express.
+func PatchToMergePatch(doc []byte, patch Patch) ([]byte, error) {
+	modified, err := patch.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateMergePatch(doc, modified)
+}
@@ -0,0 +1,178 @@
+package jsonpatch
+
+import (
+	"fmt"
+
+	"github.com/goccy/go-json"
+)
+
+// Conflict is a location changed in incompatible ways by both sides of a
+// three-way merge.
+type Conflict struct {
+	// Path is the location of the conflict.
+	Path string
+	// Base, Ours and Theirs are the values at Path in each document, nil
+	// where it is missing.
+	Base, Ours, Theirs json.RawMessage
+}
+
+// MergeStrategy tells Merge3WithStrategy how to resolve conflicts.
+type MergeStrategy int
+
+const (
+	// MergeFail fails the merge when there are conflicts.
+	MergeFail MergeStrategy = iota
+	// MergeOurs keeps the changes of ours where they conflict.
+	MergeOurs
+	// MergeTheirs keeps the changes of theirs where they conflict.
+	MergeTheirs
+)
+
+// Merge3 merges the changes made to base in ours and theirs, failing with
+// the conflicts found if any. See Merge3WithStrategy.
+func Merge3(base, ours, theirs []byte) ([]byte, []Conflict, error) {
+	return Merge3WithStrategy(base, ours, theirs, MergeFail)
+}
+
+// Merge3WithStrategy merges the changes made to base in ours and theirs:
+// both are diffed against base and the patch of theirs is transformed to
+// apply after the one of ours, as done by Transform. Identical changes are
+// applied once and elements appended to the same array by both sides are
+// appended in that order.
+//
+// Changes touching the same location are reported as conflicts and resolved
+// by strategy, along with the changes depending on the discarded ones. With
+// MergeFail, the conflicts are returned with an error wrapping ErrConflict.
+func Merge3WithStrategy(base, ours, theirs []byte, strategy MergeStrategy) ([]byte, []Conflict, error) {
+	for _, doc := range [][]byte{base, ours, theirs} {
+		if !validJSON(doc) {
+			return nil, nil, fmt.Errorf("invalid JSON document: %s", doc)
+		}
+	}
+
+	a, err := diffPatch(base, ours)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := diffPatch(base, theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var conflicts []Conflict
+	var bt Patch
+
+	for {
+		var cerr *ConflictError
+		_, bt, cerr = transform(a, b, true)
+		if cerr == nil {
+			break
+		}
+
+		c := newConflict(a[cerr.A], b[cerr.B], base, ours, theirs)
+		if n := len(conflicts); n == 0 || conflicts[n-1].Path != c.Path {
+			conflicts = append(conflicts, c)
+		}
+
+		if strategy == MergeTheirs {
+			a = dropOp(a, cerr.A)
+		} else {
+			b = dropOp(b, cerr.B)
+		}
+	}
+
+	if len(conflicts) > 0 && strategy == MergeFail {
+		return nil, conflicts, fmt.Errorf("%w: %d conflicts merging documents", ErrConflict, len(conflicts))
+	}
+
+	out, err := a.Apply(base)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err = bt.Apply(out)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, conflicts, nil
+}
+
+// newConflict reports the conflict of the operations x and y on the outer of
+// their locations.
+func newConflict(x, y Operation, base, ours, theirs []byte) Conflict {
+	p, q := mustPointer(x.path()), mustPointer(y.path())
+	if q.IsPrefixOf(p) {
+		p = q
+	}
+	if len(p) > 0 && p[len(p)-1] == "-" {
+		p = p.Parent()
+	}
+
+	value := func(doc []byte) json.RawMessage {
+		v, err := p.Get(doc)
+		if err != nil {
+			return nil
+		}
+		return v
+	}
+
+	return Conflict{Path: p.String(), Base: value(base), Ours: value(ours), Theirs: value(theirs)}
+}
+
+// dropOp returns patch without its i-th operation, the following ones being
+// transformed to apply without it. The ones depending on it are dropped too.
+func dropOp(patch Patch, i int) Patch {
+	out := append(Patch{}, patch[:i]...)
+
+	// Operations undoing the dropped ones, in the order they apply.
+	undo := Patch{}
+	if op, ok := inverseOf(patch[i]); ok {
+		undo = append(undo, op)
+	}
+
+	for _, op := range patch[i+1:] {
+		x, rebased := op, make(Patch, len(undo))
+		ok := true
+		for k, u := range undo {
+			var xt, ut Operation
+			if xt, ok = transformOp(x, u, false); !ok {
+				break
+			}
+			if ut, ok = transformOp(u, x, true); !ok {
+				break
+			}
+			x, rebased[k] = xt, ut
+		}
+
+		if !ok {
+			if inv, ok := inverseOf(op); ok {
+				undo = append(Patch{inv}, undo...)
+			}
+			continue
+		}
+
+		out = append(out, x)
+		undo = rebased
+	}
+
+	return out
+}
+
+// inverseOf returns an operation undoing the changes of op to the structure
+// of the document, without the values it removed. There is none for "test".
+func inverseOf(op Operation) (Operation, bool) {
+	switch op.kind() {
+	case "add":
+		return newOperation("remove", "", op.path(), nil), true
+	case "remove":
+		return newOperation("add", "", op.path(), nil), true
+	case "replace":
+		return newOperation("replace", "", op.path(), nil), true
+	case "move":
+		return newOperation("move", op.path(), op.from(), nil), true
+	case "copy":
+		return newOperation("remove", "", op.path(), nil), true
+	}
+	return Operation{}, false
+}
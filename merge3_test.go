@@ -0,0 +1,155 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge3(t *testing.T) {
+	testCases := []struct {
+		name     string
+		base     string
+		ours     string
+		theirs   string
+		expected string
+	}{
+		{
+			name:     "different members",
+			base:     `{"a":1,"b":{"c":2,"d":3}}`,
+			ours:     `{"a":2,"b":{"c":2,"d":3}}`,
+			theirs:   `{"a":1,"b":{"c":2},"e":4}`,
+			expected: `{"a":2,"b":{"c":2},"e":4}`,
+		},
+		{
+			name:     "identical changes",
+			base:     `{"a":1,"b":2}`,
+			ours:     `{"a":3,"b":2}`,
+			theirs:   `{"a":3}`,
+			expected: `{"a":3}`,
+		},
+		{
+			name:     "appends",
+			base:     `{"l":[1,2]}`,
+			ours:     `{"l":[1,2,3]}`,
+			theirs:   `{"l":[1,2,4,5]}`,
+			expected: `{"l":[1,2,3,4,5]}`,
+		},
+		{
+			name:     "shifted elements",
+			base:     `{"l":[{"v":1},{"v":2},{"v":3}]}`,
+			ours:     `{"l":[{"v":0},{"v":1},{"v":2},{"v":3}]}`,
+			theirs:   `{"l":[{"v":1},{"v":3,"w":true}]}`,
+			expected: `{"l":[{"v":0},{"v":1},{"v":3,"w":true}]}`,
+		},
+		{
+			name:     "nested changes",
+			base:     `{"user":{"name":"Jane","tags":["a"]},"n":1}`,
+			ours:     `{"user":{"name":"Jane Doe","tags":["a"]},"n":1}`,
+			theirs:   `{"user":{"name":"Jane","tags":["a","b"]},"n":2}`,
+			expected: `{"user":{"name":"Jane Doe","tags":["a","b"]},"n":2}`,
+		},
+		{
+			name:     "empty objects with spaces",
+			base:     `{"x":{ },"y":1}`,
+			ours:     `{ "x":{ }, "y":2 }`,
+			theirs:   `{"x":{ "z":[ ] },"y":1}`,
+			expected: `{"x":{"z":[]},"y":2}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, conflicts, err := Merge3([]byte(tc.base), []byte(tc.ours), []byte(tc.theirs))
+			require.NoError(t, err)
+			assert.Empty(t, conflicts)
+			assert.True(t, Equal([]byte(tc.expected), out), "expected %s got %s", tc.expected, out)
+		})
+	}
+}
+
+func TestMerge3Conflicts(t *testing.T) {
+	base := []byte(`{"a":1,"b":{"c":1},"d":[1,2],"e":1}`)
+	ours := []byte(`{"a":2,"d":[1,2],"e":2}`)
+	theirs := []byte(`{"a":3,"b":{"c":1,"x":1,"y":2},"d":[1,2],"e":1,"f":1}`)
+
+	out, conflicts, err := Merge3(base, ours, theirs)
+	assert.Nil(t, out)
+	assert.True(t, errors.Is(err, ErrConflict))
+	require.Equal(t, 2, len(conflicts))
+
+	assert.Equal(t, "/a", conflicts[0].Path)
+	assert.Equal(t, `1`, string(conflicts[0].Base))
+	assert.Equal(t, `2`, string(conflicts[0].Ours))
+	assert.Equal(t, `3`, string(conflicts[0].Theirs))
+
+	assert.Equal(t, "/b", conflicts[1].Path)
+	assert.Equal(t, `{"c":1}`, string(conflicts[1].Base))
+	assert.Nil(t, conflicts[1].Ours)
+	assert.Equal(t, `{"c":1,"x":1,"y":2}`, string(conflicts[1].Theirs))
+
+	out, conflicts, err = Merge3WithStrategy(base, ours, theirs, MergeOurs)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(conflicts))
+	assert.True(t, Equal([]byte(`{"a":2,"d":[1,2],"e":2,"f":1}`), out), "got %s", out)
+
+	out, conflicts, err = Merge3WithStrategy(base, ours, theirs, MergeTheirs)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(conflicts))
+	assert.True(t, Equal([]byte(`{"a":3,"b":{"c":1,"x":1,"y":2},"d":[1,2],"e":2,"f":1}`), out), "got %s", out)
+}
+
+func TestMerge3ArrayConflicts(t *testing.T) {
+	base := []byte(`{"l":[1,2,3,4]}`)
+	ours := []byte(`{"l":[1,5,3]}`)
+	theirs := []byte(`{"l":[1,3,4,6]}`)
+
+	_, conflicts, err := Merge3(base, ours, theirs)
+	assert.True(t, errors.Is(err, ErrConflict))
+	require.Equal(t, 1, len(conflicts))
+	assert.Equal(t, "/l/1", conflicts[0].Path)
+
+	out, _, err := Merge3WithStrategy(base, ours, theirs, MergeOurs)
+	require.NoError(t, err)
+	assert.Equal(t, `{"l":[1,5,3,6]}`, string(out))
+
+	out, _, err = Merge3WithStrategy(base, ours, theirs, MergeTheirs)
+	require.NoError(t, err)
+	assert.Equal(t, `{"l":[1,3,6]}`, string(out))
+}
+
+func TestMerge3Fixtures(t *testing.T) {
+	pairs := [][]string{
+		{simpleA, simpleB}, {simpleA, simpleC}, {simpleA, simpleD}, {simpleA, simpleE}, {simpleA, simplef},
+		{complexBase, complexA}, {complexBase, complexB}, {complexBase, complexC},
+		{hyperComplexBase, hyperComplexA},
+		{superComplexBase, superComplexA},
+		{subArray1_current, subArray1_target},
+		{simpleA, empty},
+	}
+
+	for i, pair := range pairs {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			base, changed := []byte(pair[0]), []byte(pair[1])
+			for _, docs := range [][2][]byte{{base, changed}, {changed, base}, {changed, changed}} {
+				out, conflicts, err := Merge3(base, docs[0], docs[1])
+				require.NoError(t, err)
+				assert.Empty(t, conflicts)
+				assert.True(t, Equal(changed, out), "expected %s got %s", changed, out)
+			}
+		})
+	}
+}
+
+func TestMerge3Invalid(t *testing.T) {
+	_, _, err := Merge3([]byte(`{}`), []byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+
+	out, conflicts, err := Merge3([]byte(`{"a":1}`), []byte(`[1]`), []byte(`{"a":1}`))
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, `[1]`, string(out))
+}
@@ -0,0 +1,135 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc7386Cases are the examples of RFC 7386 appendix A.
+var rfc7386Cases = []struct {
+	original, patch, result string
+}{
+	{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+	{`{"a":"b"}`, `{"a":null}`, `{}`},
+	{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+	{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+	{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+	{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+	{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+	{`{"a":"b"}`, `["c"]`, `["c"]`},
+	{`{"a":"foo"}`, `null`, `null`},
+	{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+	{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+	{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+}
+
+func TestMergePatchRFC7386(t *testing.T) {
+	for _, c := range rfc7386Cases {
+		out, err := MergePatch([]byte(c.original), []byte(c.patch))
+		require.NoError(t, err)
+		assert.Equal(t, c.result, string(out), "%s merged with %s", c.original, c.patch)
+	}
+}
+
+func TestMergePatchSection3(t *testing.T) {
+	original := `{
+		"title": "Goodbye!",
+		"author": {"givenName": "John", "familyName": "Doe"},
+		"tags": ["example", "sample"],
+		"content": "This will be unchanged"
+	}`
+	patch := `{
+		"title": "Hello!",
+		"phoneNumber": "+01-123-456-7890",
+		"author": {"familyName": null},
+		"tags": ["example"]
+	}`
+	expected := `{"author":{"givenName":"John"},"content":"This will be unchanged","phoneNumber":"+01-123-456-7890","tags":["example"],"title":"Hello!"}`
+
+	out, err := MergePatch([]byte(original), []byte(patch))
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(out))
+
+	created, err := CreateMergePatch([]byte(original), []byte(expected))
+	require.NoError(t, err)
+	assert.Equal(t, `{"author":{"familyName":null},"phoneNumber":"+01-123-456-7890","tags":["example"],"title":"Hello!"}`, string(created))
+}
+
+func TestCreateMergePatchRoundTrip(t *testing.T) {
+	for _, c := range rfc7386Cases {
+		created, err := CreateMergePatch([]byte(c.original), []byte(c.result))
+		require.NoError(t, err)
+		out, err := MergePatch([]byte(c.original), created)
+		require.NoError(t, err)
+		assert.Equal(t, c.result, string(out), "%s with %s", c.original, created)
+	}
+
+	created, err := CreateMergePatch([]byte(`{"a":{"b":1},"c":[1]}`), []byte(`{"a":{"b":1},"c":[1]}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{}`, string(created))
+}
+
+func TestMergePatchToPatch(t *testing.T) {
+	for _, c := range rfc7386Cases {
+		patch, err := MergePatchToPatch([]byte(c.original), []byte(c.patch))
+		require.NoError(t, err)
+		out, err := patch.Apply([]byte(c.original))
+		require.NoError(t, err)
+		assert.Equal(t, c.result, string(out), "%s converted from %s", c.original, c.patch)
+
+		merge, err := PatchToMergePatch([]byte(c.original), patch)
+		require.NoError(t, err)
+		out, err = MergePatch([]byte(c.original), merge)
+		require.NoError(t, err)
+		assert.Equal(t, c.result, string(out), "%s converted back to %s", c.original, merge)
+	}
+
+	patch, err := MergePatchToPatch([]byte(`{"a":{"b":1},"c":2,"d~/":3}`), []byte(`{"a":{"b":null,"x":{"y":null}},"c":3,"d~/":null,"z":null}`))
+	require.NoError(t, err)
+	require.Equal(t, 4, len(patch))
+	assert.Equal(t, "remove", patch[0].kind())
+	assert.Equal(t, "/a/b", patch[0].path())
+	assert.Equal(t, "add", patch[1].kind())
+	assert.Equal(t, "/a/x", patch[1].path())
+	assert.Equal(t, "replace", patch[2].kind())
+	assert.Equal(t, "/c", patch[2].path())
+	assert.Equal(t, "remove", patch[3].kind())
+	assert.Equal(t, "/d~0~1", patch[3].path())
+}
+
+func TestMergePatchNotRepresentable(t *testing.T) {
+	for _, c := range [][2]string{
+		{`{"a":1}`, `{"a":null}`},
+		{`{}`, `{"a":null}`},
+		{`{"a":1}`, `{"a":{"b":null}}`},
+		{`1`, `{"a":null}`},
+	} {
+		_, err := CreateMergePatch([]byte(c[0]), []byte(c[1]))
+		assert.True(t, errors.Is(err, ErrNotRepresentable), "%s to %s", c[0], c[1])
+	}
+
+	patch, err := DecodePatch([]byte(`[{"op":"add","path":"/a","value":null}]`))
+	require.NoError(t, err)
+	_, err = PatchToMergePatch([]byte(`{}`), patch)
+	assert.True(t, errors.Is(err, ErrNotRepresentable))
+
+	// Nulls inside arrays are kept, arrays being replaced as a whole.
+	created, err := CreateMergePatch([]byte(`{"a":[1]}`), []byte(`{"a":[null,{"b":null}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[null,{"b":null}]}`, string(created))
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	_, err := MergePatch([]byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+	_, err = MergePatch([]byte(`{}`), []byte(`{"a"`))
+	assert.Error(t, err)
+	_, err = CreateMergePatch([]byte(``), []byte(`{}`))
+	assert.Error(t, err)
+}
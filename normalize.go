@@ -0,0 +1,14 @@
+package jsonpatch
+
+// Normalize returns a patch equivalent to p on any document p applies to,
+// without its redundant operations: they are merged by the rules of Compose,
+// and "test" operations implied by the preceding ones are dropped.
+func (p Patch) Normalize() (Patch, error) {
+	return Compose(p)
+}
+
+// Normalize returns operations equivalent to ops without the redundant ones,
+// see Patch.Normalize.
+func Normalize(ops []Operation) ([]Operation, error) {
+	return Patch(ops).Normalize()
+}
@@ -0,0 +1,152 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		patch    string
+		expected string
+	}{
+		{
+			name:     "successive replaces",
+			patch:    `[{"op":"replace","path":"/a","value":1},{"op":"replace","path":"/a","value":2}]`,
+			expected: `[{"op":"replace","path":"/a","value":2}]`,
+		},
+		{
+			name:     "add then remove of a removed key",
+			patch:    `[{"op":"remove","path":"/a"},{"op":"add","path":"/a","value":1},{"op":"remove","path":"/a"}]`,
+			expected: `[{"op":"remove","path":"/a"}]`,
+		},
+		{
+			name:     "implied tests",
+			patch:    `[{"op":"add","path":"/a","value":{"b":1}},{"op":"test","path":"/a","value":{"b":1}},{"op":"test","path":"/c","value":2},{"op":"test","path":"/c","value":2}]`,
+			expected: `[{"op":"add","path":"/a","value":{"b":1}},{"op":"test","path":"/c","value":2}]`,
+		},
+		{
+			name:     "writes below a replaced location",
+			patch:    `[{"op":"add","path":"/a/b","value":1},{"op":"remove","path":"/a/c"},{"op":"replace","path":"/a","value":{}}]`,
+			expected: `[{"op":"replace","path":"/a","value":{}}]`,
+		},
+		{
+			name:     "different tests",
+			patch:    `[{"op":"test","path":"/a","value":1},{"op":"test","path":"/a","value":2}]`,
+			expected: `[{"op":"test","path":"/a","value":1},{"op":"test","path":"/a","value":2}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := DecodePatch([]byte(tc.patch))
+			require.NoError(t, err)
+			normalized, err := patch.Normalize()
+			require.NoError(t, err)
+			out, err := json.Marshal(normalized)
+			require.NoError(t, err)
+			assert.True(t, Equal([]byte(tc.expected), out), "expected %s got %s", tc.expected, out)
+		})
+	}
+}
+
+func TestNormalizeOperations(t *testing.T) {
+	ops := []Operation{
+		NewPatch("add", "/a", map[string]interface{}{"b": 1}),
+		NewPatch("replace", "/a/b", 2),
+		{Op: "copy", From: "/a", Path: "/c"},
+	}
+
+	normalized, err := Normalize(ops)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(normalized))
+	assert.Equal(t, `{"op":"add","path":"/a","value":{"b":2}}`, normalized[0].JSON())
+	assert.Equal(t, `{"op":"copy","path":"/c","from":"/a"}`, normalized[1].JSON())
+}
+
+// Normalized patches apply like the ones they come from, on every document
+// they apply to.
+func TestNormalizeEquivalence(t *testing.T) {
+	docs := []string{`{}`, `{"k":null}`, `{"a":1,"k":0}`, `{"a":{"b":1},"c":[1,2]}`, `[1,2]`}
+	patches := []string{
+		`[{"op":"replace","path":"/k","value":1},{"op":"remove","path":"/k"}]`,
+		`[{"op":"replace","path":"/k","value":1},{"op":"replace","path":"/k","value":2}]`,
+		`[{"op":"add","path":"/k","value":1},{"op":"replace","path":"/k","value":2}]`,
+		`[{"op":"add","path":"/k","value":1},{"op":"remove","path":"/k"}]`,
+		`[{"op":"remove","path":"/k"},{"op":"add","path":"/k","value":1},{"op":"remove","path":"/k"}]`,
+		`[{"op":"replace","path":"/k","value":{"x":1}},{"op":"test","path":"/k","value":{"x":1}}]`,
+		`[{"op":"test","path":"/k","value":null},{"op":"test","path":"/k","value":null}]`,
+		`[{"op":"replace","path":"/a/b","value":2},{"op":"remove","path":"/a"}]`,
+		`[{"op":"add","path":"/a/b","value":1},{"op":"replace","path":"/a","value":{}}]`,
+		`[{"op":"add","path":"/c/-","value":3},{"op":"replace","path":"/c","value":[]}]`,
+		`[{"op":"replace","path":"/0","value":3},{"op":"remove","path":"/0"}]`,
+		`[{"op":"add","path":"/x","value":{"y":[1]}},{"op":"add","path":"/x/y/-","value":2},{"op":"remove","path":"/x/y/0"}]`,
+		`[{"op":"replace","path":"/a","value":{"b":1}},{"op":"move","from":"/a/b","path":"/a/c"}]`,
+		`[{"op":"copy","from":"/a","path":"/z"},{"op":"replace","path":"/a","value":3}]`,
+		`[{"op":"replace","path":"","value":{}},{"op":"add","path":"/k","value":1}]`,
+	}
+
+	for _, p := range patches {
+		patch, err := DecodePatch([]byte(p))
+		require.NoError(t, err)
+		normalized, err := patch.Normalize()
+		require.NoError(t, err)
+
+		for _, doc := range docs {
+			expected, err := patch.Apply([]byte(doc))
+			if err != nil {
+				continue
+			}
+			out, err := normalized.Apply([]byte(doc))
+			if assert.NoError(t, err, "%s normalized to %v on %s", p, normalized, doc) {
+				assert.True(t, Equal(expected, out), "%s on %s: expected %s got %s", p, doc, expected, out)
+			}
+		}
+	}
+}
+
+// Normalized patches apply like the ones they come from.
+func TestNormalizeFixtures(t *testing.T) {
+	pairs := [][]string{
+		{simpleA, simpleB}, {simpleA, simpleC}, {simpleA, simpleD}, {simpleA, simpleE}, {simpleA, simplef}, {simpleA, simpleG},
+		{complexBase, complexA}, {complexBase, complexB}, {complexBase, complexC}, {complexBase, empty},
+		{hyperComplexBase, hyperComplexA},
+		{superComplexBase, superComplexA},
+		{collectionWindowAscBefore, collectionWindowAscAfter}, {collectionWindowDscBefore, collectionWindowDscAfter},
+		{subArray1_current, subArray1_target}, {subArray2_current, subArray2_target}, {subArray5_current, subArray5__target},
+	}
+
+	options := []DiffOptions{{}, {DetectMovesAndCopies: true}, {DefaultArrayKey: "id"}}
+
+	for i, pair := range pairs {
+		for j, opts := range options {
+			t.Run(fmt.Sprint(i, "/", j), func(t *testing.T) {
+				a, b := []byte(pair[0]), []byte(pair[1])
+				ops, err := CreatePatchWithOptions(a, b, opts)
+				require.NoError(t, err)
+
+				normalized, err := ops.Normalize()
+				require.NoError(t, err)
+				assert.True(t, len(normalized) <= len(ops))
+				assert.True(t, Equal(applyOperations(t, a, ops), applyOperations(t, a, normalized)))
+
+				// Applying the patch twice is equivalent to its normalization.
+				patch := append(ops, ops...)
+				twice, err := patch.Normalize()
+				require.NoError(t, err)
+				expected, err := patch.Apply(a)
+				if err != nil {
+					return
+				}
+				out, err := twice.Apply(a)
+				require.NoError(t, err)
+				assert.True(t, Equal(expected, out), "expected %s got %s", expected, out)
+			})
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/goccy/go-json"
 )
@@ -16,15 +15,61 @@ const (
 )
 
 var (
-	// SupportNegativeIndices decides whether to support non-standard practice of
-	// allowing negative indices to mean indices starting at the end of an array.
+	// SupportNegativeIndices is the default of
+	// ApplyOptions.SupportNegativeIndices used by Apply and ApplyIndent.
 	// Default to true.
 	SupportNegativeIndices = true
-	// AccumulatedCopySizeLimit limits the total size increase in bytes caused by
-	// "copy" operations in a patch.
+	// AccumulatedCopySizeLimit is the default of
+	// ApplyOptions.AccumulatedCopySizeLimit used by Apply and ApplyIndent.
 	AccumulatedCopySizeLimit = int64(0)
 )
 
+// ApplyOptions specifies options for calls to ApplyWithOptions.
+// Use NewApplyOptions to obtain default values for ApplyOptions.
+type ApplyOptions struct {
+	// SupportNegativeIndices decides whether to support non-standard practice of
+	// allowing negative indices to mean indices starting at the end of an array.
+	SupportNegativeIndices bool
+	// AccumulatedCopySizeLimit limits the total size increase in bytes caused by
+	// "copy" operations in a patch, zero means no limit.
+	AccumulatedCopySizeLimit int64
+	// Indent is used to indent the resulting document, no indentation if empty.
+	Indent string
+	// EnsurePathExistsOnAdd instructs "add" operations to create the missing
+	// containers along their path: an array when the following token is "0"
+	// or "-", an object otherwise.
+	EnsurePathExistsOnAdd bool
+	// PreserveFormatting keeps the text of the document: the values the patch
+	// does not reach are copied as they are, and the objects and arrays it
+	// changes keep their member order and spacing, new members being appended
+	// in the style of their siblings. Indent is not used then.
+	PreserveFormatting bool
+	// Limits bounds the resources used by the patch.
+	Limits Limits
+	// Strict enforces every requirement of RFC 6902 and RFC 6901: the
+	// operations reading a location fail when it is missing, array indices
+	// with a sign or a leading zero are rejected, a missing value is not taken
+	// for null and "test" compares numbers by value. SupportNegativeIndices
+	// and EnsurePathExistsOnAdd are not used then.
+	Strict bool
+	// Predicates enables the extension operations checking a condition on
+	// the document, failing like "test" when it does not hold or holds with
+	// a "not" member set to true: "exists" and "absent" on the presence of a
+	// value, "type" on its JSON type, "matches" on a string matching a
+	// regular expression, "range" on a number within {"min": ..., "max":
+	// ...} and "contains" on an array holding a value.
+	Predicates bool
+}
+
+// NewApplyOptions creates a default set of options for calls to
+// ApplyWithOptions, taken from the package level variables.
+func NewApplyOptions() *ApplyOptions {
+	return &ApplyOptions{
+		SupportNegativeIndices:   SupportNegativeIndices,
+		AccumulatedCopySizeLimit: AccumulatedCopySizeLimit,
+	}
+}
+
 type lazyNode struct {
 	raw   *json.RawMessage
 	doc   partialDoc
@@ -32,10 +77,9 @@ type lazyNode struct {
 	which int
 }
 
-type operation map[string]*json.RawMessage
-
-// Patch is an ordered collection of operations.
-type Patch []operation
+// Patch is an ordered collection of operations, CreatePatch returns one
+// ready to apply.
+type Patch []Operation
 
 type partialDoc map[string]*lazyNode
 type partialArray []*lazyNode
@@ -43,8 +87,8 @@ type partialArray []*lazyNode
 type container interface {
 	get(key string) (*lazyNode, error)
 	set(key string, val *lazyNode) error
-	add(key string, val *lazyNode) error
-	remove(key string) error
+	add(key string, val *lazyNode, options *ApplyOptions) error
+	remove(key string, options *ApplyOptions) error
 }
 
 func newLazyNode(raw *json.RawMessage) *lazyNode {
@@ -107,6 +151,10 @@ func (n *lazyNode) intoDoc() (*partialDoc, error) {
 		return nil, err
 	}
 
+	if n.doc == nil {
+		return nil, fmt.Errorf("unable to unmarshal null as partial document")
+	}
+
 	n.which = eDoc
 	return &n.doc, nil
 }
@@ -235,7 +283,16 @@ func (n *lazyNode) equal(o *lazyNode) bool {
 	}
 
 	for idx, val := range n.ary {
-		if !val.equal(o.ary[idx]) {
+		ov := o.ary[idx]
+
+		if val == nil || ov == nil {
+			if val != ov {
+				return false
+			}
+			continue
+		}
+
+		if !val.equal(ov) {
 			return false
 		}
 	}
@@ -243,69 +300,75 @@ func (n *lazyNode) equal(o *lazyNode) bool {
 	return true
 }
 
-func (o operation) kind() string {
-	if obj, ok := o["op"]; ok && obj != nil {
-		var op string
+func (o Operation) kind() string {
+	return o.Op
+}
 
-		err := json.Unmarshal(*obj, &op)
+func (o Operation) path() string {
+	return o.Path
+}
 
-		if err != nil {
-			return "unknown"
-		}
+func (o Operation) from() string {
+	return o.From
+}
 
-		return op
+// value returns the value of o, see newValueNode. The values of operations
+// not decoded are marshaled, validate reports the ones that cannot be.
+func (o Operation) value() *lazyNode {
+	raw, err := o.rawValue()
+	if err != nil {
+		return nil
 	}
 
-	return "unknown"
+	return newValueNode(raw)
 }
 
-func (o operation) path() string {
-	if obj, ok := o["path"]; ok && obj != nil {
-		var op string
-
-		err := json.Unmarshal(*obj, &op)
-
-		if err != nil {
-			return "unknown"
-		}
+// newValueNode returns a node holding the value raw of an operation, nil if
+// it has none and a node without raw value if it is null.
+func newValueNode(raw json.RawMessage) *lazyNode {
+	if raw == nil {
+		return nil
+	}
 
-		return op
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return newLazyNode(nil)
 	}
 
-	return "unknown"
+	return newLazyNode(&raw)
 }
 
-func (o operation) from() string {
-	if obj, ok := o["from"]; ok && obj != nil {
-		var op string
-
-		err := json.Unmarshal(*obj, &op)
-
-		if err != nil {
-			return "unknown"
-		}
-
-		return op
+func (o Operation) rawValue() (json.RawMessage, error) {
+	switch v := o.Value.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		return v, nil
 	}
 
-	return "unknown"
+	return json.Marshal(o.Value)
 }
 
-func (o operation) value() *lazyNode {
-	if obj, ok := o["value"]; ok {
-		return newLazyNode(obj)
+// newOperation builds an operation of the given kind on path, from is only
+// used by "move" and "copy" and value by "add", "replace" and "test".
+func newOperation(kind, from, path string, value *lazyNode) Operation {
+	op := Operation{Op: kind, Path: path}
+
+	switch kind {
+	case "move", "copy":
+		op.From = from
+	case "add", "replace", "test":
+		v, _ := json.Marshal(value)
+		op.Value = json.RawMessage(v)
 	}
 
-	return nil
+	return op
 }
 
 func isArray(buf []byte) bool {
 Loop:
 	for _, c := range buf {
 		switch c {
-		case ' ':
-		case '\n':
-		case '\t':
+		case ' ', '\n', '\t', '\r':
 			continue
 		case '[':
 			return true
@@ -317,45 +380,82 @@ Loop:
 	return false
 }
 
-func findObject(pd *container, path string) (container, string) {
-	doc := *pd
-
-	split := strings.Split(path, "/")
+func (n *lazyNode) intoContainer() (container, error) {
+	switch n.which {
+	case eDoc:
+		return &n.doc, nil
+	case eAry:
+		return &n.ary, nil
+	}
 
-	if len(split) < 2 {
-		return nil, ""
+	if n.raw != nil && isArray(*n.raw) {
+		return n.intoAry()
 	}
 
-	parts := split[1 : len(split)-1]
+	return n.intoDoc()
+}
 
-	key := split[len(split)-1]
+// rootDoc is the container of a whole document, which is held under the
+// empty key. A nil node is a null document.
+type rootDoc struct {
+	node *lazyNode
+}
 
-	var err error
+func newRootDoc(doc []byte) (*rootDoc, error) {
+	var raw json.RawMessage
 
-	for _, part := range parts {
+	err := json.Unmarshal(doc, &raw)
 
-		next, ok := doc.get(decodePatchKey(part))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return &rootDoc{}, nil
+	}
+
+	node := newLazyNode(&raw)
 
-		if next == nil || ok != nil {
-			return nil, ""
+	if raw[0] == '{' || raw[0] == '[' {
+		if _, err := node.intoContainer(); err != nil {
+			return nil, err
 		}
+	}
 
-		if isArray(*next.raw) {
-			doc, err = next.intoAry()
+	return &rootDoc{node: node}, nil
+}
 
-			if err != nil {
-				return nil, ""
-			}
-		} else {
-			doc, err = next.intoDoc()
+func (d *rootDoc) get(key string) (*lazyNode, error) {
+	if key != "" {
+		return nil, errPathNotFound(key)
+	}
 
-			if err != nil {
-				return nil, ""
-			}
-		}
+	return d.node, nil
+}
+
+func (d *rootDoc) set(key string, val *lazyNode) error {
+	return d.add(key, val, nil)
+}
+
+// add replaces the whole document, as required by RFC 6902 for an "add"
+// operation on the root.
+func (d *rootDoc) add(key string, val *lazyNode, options *ApplyOptions) error {
+	if key != "" {
+		return errPathNotFound(key)
 	}
 
-	return doc, decodePatchKey(key)
+	d.node = val
+	return nil
+}
+
+// remove leaves a null document.
+func (d *rootDoc) remove(key string, options *ApplyOptions) error {
+	if key != "" {
+		return errPathNotFound(key)
+	}
+
+	d.node = nil
+	return nil
 }
 
 func (d *partialDoc) set(key string, val *lazyNode) error {
@@ -363,7 +463,7 @@ func (d *partialDoc) set(key string, val *lazyNode) error {
 	return nil
 }
 
-func (d *partialDoc) add(key string, val *lazyNode) error {
+func (d *partialDoc) add(key string, val *lazyNode, options *ApplyOptions) error {
 	(*d)[key] = val
 	return nil
 }
@@ -372,10 +472,10 @@ func (d *partialDoc) get(key string) (*lazyNode, error) {
 	return (*d)[key], nil
 }
 
-func (d *partialDoc) remove(key string) error {
+func (d *partialDoc) remove(key string, options *ApplyOptions) error {
 	_, ok := (*d)[key]
 	if !ok {
-		return fmt.Errorf("unable to remove nonexistent key: %s", key)
+		return errPathNotFound(key)
 	}
 
 	delete(*d, key)
@@ -387,13 +487,22 @@ func (d *partialDoc) remove(key string) error {
 func (d *partialArray) set(key string, val *lazyNode) error {
 	idx, err := strconv.Atoi(key)
 	if err != nil {
-		return err
+		return errInvalidIndex(key)
 	}
+
+	if idx < 0 || idx >= len(*d) {
+		return errInvalidIndex(key)
+	}
+
 	(*d)[idx] = val
 	return nil
 }
 
-func (d *partialArray) add(key string, val *lazyNode) error {
+func (d *partialArray) add(key string, val *lazyNode, options *ApplyOptions) error {
+	if max := options.Limits.MaxArrayLength; max > 0 && len(*d) >= max {
+		return NewArraySizeError(max, len(*d)+1)
+	}
+
 	if key == "-" {
 		*d = append(*d, val)
 		return nil
@@ -401,32 +510,32 @@ func (d *partialArray) add(key string, val *lazyNode) error {
 
 	idx, err := strconv.Atoi(key)
 	if err != nil {
-		return err
+		return errInvalidIndex(key)
 	}
 
 	sz := len(*d) + 1
 
-	ary := make([]*lazyNode, sz)
-
-	cur := *d
-
-	if idx >= len(ary) {
-		return fmt.Errorf("(add) Unable to access invalid index: %d", idx)
+	if idx >= sz {
+		return errInvalidIndex(key)
 	}
 
-	if SupportNegativeIndices {
-		if idx < -len(ary) {
-			return fmt.Errorf("(add) Unable to access invalid index: %d", idx)
+	if options.SupportNegativeIndices {
+		if idx < -sz {
+			return errInvalidIndex(key)
 		}
 
 		if idx < 0 {
-			idx += len(ary)
+			idx += sz
 		}
+	} else if idx < 0 {
+		return errInvalidIndex(key)
 	}
 
-	copy(ary[0:idx], cur[0:idx])
+	// Shift the elements in place, append reserving room for the next
+	// insertions.
+	ary := append(*d, nil)
+	copy(ary[idx+1:], ary[idx:])
 	ary[idx] = val
-	copy(ary[idx+1:], cur[idx:])
 
 	*d = ary
 	return nil
@@ -436,96 +545,279 @@ func (d *partialArray) get(key string) (*lazyNode, error) {
 	idx, err := strconv.Atoi(key)
 
 	if err != nil {
-		return nil, err
+		return nil, errInvalidIndex(key)
 	}
 
-	if idx >= len(*d) {
-		return nil, fmt.Errorf("(get) Unable to access invalid index: %d", idx)
+	if idx < 0 || idx >= len(*d) {
+		return nil, errInvalidIndex(key)
 	}
 
 	return (*d)[idx], nil
 }
 
-func (d *partialArray) remove(key string) error {
+func (d *partialArray) remove(key string, options *ApplyOptions) error {
 	idx, err := strconv.Atoi(key)
 	if err != nil {
-		return err
+		return errInvalidIndex(key)
 	}
 
 	cur := *d
 
 	if idx >= len(cur) {
-		return fmt.Errorf("(remove) Unable to access invalid index: %d", idx)
+		return errInvalidIndex(key)
 	}
 
-	if SupportNegativeIndices {
+	if options.SupportNegativeIndices {
 		if idx < -len(cur) {
-			return fmt.Errorf("(remove) Unable to access invalid index: %d", idx)
+			return errInvalidIndex(key)
 		}
 
 		if idx < 0 {
 			idx += len(cur)
 		}
+	} else if idx < 0 {
+		return errInvalidIndex(key)
 	}
 
-	ary := make([]*lazyNode, len(cur)-1)
-
-	copy(ary[0:idx], cur[0:idx])
-	copy(ary[idx:], cur[idx+1:])
+	// Shift the elements in place, clearing the last slot so that the
+	// removed node can be collected.
+	copy(cur[idx:], cur[idx+1:])
+	cur[len(cur)-1] = nil
 
-	*d = ary
+	*d = cur[:len(cur)-1]
 	return nil
+}
 
+// applier applies operations to a document, recording the operations
+// reverting them in undo unless nil.
+type applier struct {
+	// source is the document the patch applies to.
+	source  []byte
+	root    *rootDoc
+	doc     container
+	options *ApplyOptions
+	undo    *undoLog
+	// copied is the size increase caused by the "copy" operations so far.
+	copied int64
+	// size bounds the size of the encoded document, for MaxDocumentSize to be
+	// enforced while the operations apply.
+	size int64
 }
 
-func (p Patch) add(doc *container, op operation) error {
-	path := op.path()
+func newApplier(doc []byte, options *ApplyOptions, undo *undoLog) (*applier, error) {
+	root, err := newRootDoc(doc)
 
-	con, key := findObject(doc, path)
+	if err != nil {
+		return nil, err
+	}
 
-	if con == nil {
-		return fmt.Errorf("jsonpatch add operation does not apply: doc is missing path: \"%s\"", path)
+	return &applier{source: doc, root: root, doc: root, options: options, undo: undo, size: int64(len(doc))}, nil
+}
+
+// apply applies a single operation.
+func (a *applier) apply(op *compiledOp) error {
+	if err := a.options.Limits.checkOperation(op); err != nil {
+		return err
+	}
+
+	if a.options.Strict {
+		if err := a.checkStrict(op); err != nil {
+			return err
+		}
 	}
 
-	return con.add(key, op.value())
+	switch op.Op {
+	case "add", "replace":
+		if err := a.grow(int64(len(op.value))); err != nil {
+			return err
+		}
+	case "test":
+		// A negated test is an extension operation.
+		if op.Not && !a.options.Predicates {
+			return fmt.Errorf("%w: negated test", ErrUnknownOp)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return a.add(op)
+	case "remove":
+		return a.remove(op)
+	case "replace":
+		return a.replace(op)
+	case "move":
+		return a.move(op)
+	case "test":
+		return a.test(op)
+	case "copy":
+		return a.copy(op)
+	}
+
+	if op.pred != nil && a.options.Predicates {
+		return a.predicate(op)
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownOp, op.Op)
 }
 
-func (p Patch) remove(doc *container, op operation) error {
-	path := op.path()
+// grow accounts for n bytes added to the document, failing with a *LimitError
+// as soon as it may exceed MaxDocumentSize. Removed values are not deducted.
+func (a *applier) grow(n int64) error {
+	a.size += n
+	return a.options.Limits.check("MaxDocumentSize", a.options.Limits.MaxDocumentSize, int(a.size))
+}
 
-	con, key := findObject(doc, path)
+// result returns the patched document.
+func (a *applier) result() ([]byte, error) {
+	doc, err := a.encode()
+	if err != nil {
+		return nil, err
+	}
 
-	if con == nil {
-		return fmt.Errorf("jsonpatch remove operation does not apply: doc is missing path: \"%s\"", path)
+	if err := a.options.Limits.checkDocument(doc); err != nil {
+		return nil, err
 	}
 
-	return con.remove(key)
+	return doc, nil
 }
 
-func (p Patch) replace(doc *container, op operation) error {
-	path := op.path()
+func (a *applier) encode() ([]byte, error) {
+	if a.options.PreserveFormatting {
+		// The spaces surrounding the root value are kept as well.
+		trimmed := bytes.TrimLeft(a.source, " \t\n\r")
+		value := bytes.TrimRight(trimmed, " \t\n\r")
 
-	con, key := findObject(doc, path)
+		buf := &bytes.Buffer{}
+		buf.Write(a.source[:len(a.source)-len(trimmed)])
+		if err := writePreserving(buf, a.root.node); err != nil {
+			return nil, err
+		}
+		buf.Write(trimmed[len(value):])
+		return buf.Bytes(), nil
+	}
 
-	if con == nil {
-		return fmt.Errorf("jsonpatch replace operation does not apply: doc is missing path: %s", path)
+	if a.options.Indent != "" {
+		return json.MarshalIndent(a.root.node, "", a.options.Indent)
 	}
 
-	_, ok := con.get(key)
-	if ok != nil {
-		return fmt.Errorf("jsonpatch replace operation does not apply: doc is missing key: %s", path)
+	return json.Marshal(a.root.node)
+}
+
+func (a *applier) add(op *compiledOp) error {
+	if a.options.EnsurePathExistsOnAdd && !a.options.Strict {
+		err := ensurePathExists(&a.doc, op.path, a.options, a.undo)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	con, key, err := op.path.find(a.doc)
+
+	if err != nil {
+		return err
 	}
 
-	return con.set(key, op.value())
+	mark := a.undo.mark()
+	a.undo.added(con, op.Path, key, a.options)
+
+	return a.undo.failed(mark, con.add(key, op.node(), a.options))
 }
 
-func (p Patch) move(doc *container, op operation) error {
-	from := op.from()
+// ensurePathExists creates the missing or null containers along p, all but
+// its last token, so that it resolves. Array elements are only created by
+// appending to the array with the index of its end, since "-" would not
+// resolve afterwards.
+func ensurePathExists(pd *container, p Pointer, options *ApplyOptions, undo *undoLog) error {
+	doc := *pd
+	key := ""
+
+	for i, token := range p {
+		next, err := doc.get(key)
+
+		if err != nil {
+			ary, ok := doc.(*partialArray)
+			if !ok || key != strconv.Itoa(len(*ary)) {
+				return err
+			}
+		}
 
-	con, key := findObject(doc, from)
+		if next == nil {
+			next = &lazyNode{doc: partialDoc{}, which: eDoc}
+			if token == "0" || token == "-" {
+				next = &lazyNode{ary: partialArray{}, which: eAry}
+			}
 
-	if con == nil {
-		return fmt.Errorf("jsonpatch move operation does not apply: doc is missing from path: %s", from)
+			mark := undo.mark()
+			if err != nil {
+				undo.added(doc, p[:i].String(), key, options)
+				err = undo.failed(mark, doc.add(key, next, options))
+			} else {
+				undo.replaced(doc, p[:i].String(), key, options)
+				err = undo.failed(mark, doc.set(key, next))
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		doc, err = next.intoContainer()
+
+		if err != nil {
+			return errPathNotFound(p.String())
+		}
+
+		key = token
+	}
+
+	return nil
+}
+
+func (a *applier) remove(op *compiledOp) error {
+	con, key, err := op.path.find(a.doc)
+
+	if err != nil {
+		return err
+	}
+
+	if err := op.path.checkMember(con, key); err != nil {
+		return err
+	}
+
+	mark := a.undo.mark()
+	a.undo.removed(con, op.Path, key, a.options)
+
+	return a.undo.failed(mark, con.remove(key, a.options))
+}
+
+func (a *applier) replace(op *compiledOp) error {
+	con, key, err := op.path.find(a.doc)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = con.get(key)
+	if err != nil {
+		return err
+	}
+
+	mark := a.undo.mark()
+	a.undo.replaced(con, op.Path, key, a.options)
+
+	return a.undo.failed(mark, con.set(key, op.node()))
+}
+
+func (a *applier) move(op *compiledOp) error {
+	con, key, err := op.from.find(a.doc)
+
+	if err != nil {
+		return err
+	}
+
+	if err := op.from.checkMember(con, key); err != nil {
+		return err
 	}
 
 	val, err := con.get(key)
@@ -533,29 +825,37 @@ func (p Patch) move(doc *container, op operation) error {
 		return err
 	}
 
-	err = con.remove(key)
+	mark := a.undo.mark()
+	a.undo.removed(con, op.From, key, a.options)
+
+	err = a.undo.failed(mark, con.remove(key, a.options))
 	if err != nil {
 		return err
 	}
 
-	path := op.path()
+	con, key, err = op.path.find(a.doc)
+
+	if err != nil {
+		return err
+	}
 
-	con, key = findObject(doc, path)
+	mark = a.undo.mark()
+	a.undo.added(con, op.Path, key, a.options)
 
-	if con == nil {
-		return fmt.Errorf("jsonpatch move operation does not apply: doc is missing destination path: %s", path)
+	err = a.undo.failed(mark, con.add(key, val, a.options))
+	if err != nil {
+		return err
 	}
 
-	return con.add(key, val)
+	a.undo.moved()
+	return nil
 }
 
-func (p Patch) test(doc *container, op operation) error {
-	path := op.path()
+func (a *applier) test(op *compiledOp) error {
+	con, key, err := op.path.find(a.doc)
 
-	con, key := findObject(doc, path)
-
-	if con == nil {
-		return fmt.Errorf("jsonpatch test operation does not apply: is missing path: %s", path)
+	if err != nil {
+		return err
 	}
 
 	val, err := con.get(key)
@@ -564,29 +864,49 @@ func (p Patch) test(doc *container, op operation) error {
 		return err
 	}
 
-	if val == nil {
-		if op.value().raw == nil {
+	expected := op.node()
+
+	if expected == nil {
+		return ErrMissingValue
+	}
+
+	if op.Not {
+		if !a.equal(val, expected) {
 			return nil
 		}
-		return fmt.Errorf("testing value %s failed", path)
-	} else if op.value() == nil {
-		return fmt.Errorf("testing value %s failed", path)
+		return fmt.Errorf("%w: value at %q is equal", ErrTestFailed, op.Path)
 	}
 
-	if val.equal(op.value()) {
+	if a.equal(val, expected) {
 		return nil
 	}
 
-	return fmt.Errorf("testing value %s failed", path)
+	return fmt.Errorf("%w: value at %q differs", ErrTestFailed, op.Path)
 }
 
-func (p Patch) copy(doc *container, op operation, accumulatedCopySize *int64) error {
-	from := op.from()
+// equal compares the value val of the document to expected like "test"
+// operations do.
+func (a *applier) equal(val, expected *lazyNode) bool {
+	if a.options.Strict {
+		return equalValues(val, expected)
+	}
+
+	if val == nil {
+		return expected.raw == nil
+	}
+
+	return val.equal(expected)
+}
 
-	con, key := findObject(doc, from)
+func (a *applier) copy(op *compiledOp) error {
+	con, key, err := op.from.find(a.doc)
 
-	if con == nil {
-		return fmt.Errorf("jsonpatch copy operation does not apply: doc is missing from path: %s", from)
+	if err != nil {
+		return err
+	}
+
+	if err := op.from.checkMember(con, key); err != nil {
+		return err
 	}
 
 	val, err := con.get(key)
@@ -594,24 +914,28 @@ func (p Patch) copy(doc *container, op operation, accumulatedCopySize *int64) er
 		return err
 	}
 
-	path := op.path()
-
-	con, key = findObject(doc, path)
+	con, key, err = op.path.find(a.doc)
 
-	if con == nil {
-		return fmt.Errorf("jsonpatch copy operation does not apply: doc is missing destination path: %s", path)
+	if err != nil {
+		return err
 	}
 
 	valCopy, sz, err := deepCopy(val)
 	if err != nil {
 		return err
 	}
-	(*accumulatedCopySize) += int64(sz)
-	if AccumulatedCopySizeLimit > 0 && *accumulatedCopySize > AccumulatedCopySizeLimit {
-		return NewAccumulatedCopySizeError(AccumulatedCopySizeLimit, *accumulatedCopySize)
+	a.copied += int64(sz)
+	if a.options.AccumulatedCopySizeLimit > 0 && a.copied > a.options.AccumulatedCopySizeLimit {
+		return NewAccumulatedCopySizeError(a.options.AccumulatedCopySizeLimit, a.copied)
+	}
+	if err := a.grow(int64(sz)); err != nil {
+		return err
 	}
 
-	return con.add(key, valCopy)
+	mark := a.undo.mark()
+	a.undo.added(con, op.Path, key, a.options)
+
+	return a.undo.failed(mark, con.add(key, valCopy, a.options))
 }
 
 // Equal indicates if 2 JSON documents have the same structural equality.
@@ -627,8 +951,21 @@ func Equal(a, b []byte) bool {
 	return la.equal(lb)
 }
 
-// DecodePatch decodes the passed JSON document as an RFC 6902 patch.
+// DecodePatch decodes the passed JSON document as an RFC 6902 patch. An
+// operation that can never apply, because of an unknown kind, a missing
+// path or from location, or a "test" without value, is reported as a
+// *PatchError. The extension operations of ApplyOptions.Predicates are
+// accepted, they only apply with the option set.
 func DecodePatch(buf []byte) (Patch, error) {
+	return DecodePatchWithLimits(buf, Limits{})
+}
+
+// DecodePatchWithLimits decodes the passed JSON document as an RFC 6902 patch,
+// see DecodePatch, checking the number of operations, the depth of their
+// pointers and the size of their values against limits. A patch with too
+// many operations is reported as a *LimitError, an operation exceeding the
+// limits as a *PatchError caused by one.
+func DecodePatchWithLimits(buf []byte, limits Limits) (Patch, error) {
 	var p Patch
 
 	err := json.Unmarshal(buf, &p)
@@ -637,76 +974,124 @@ func DecodePatch(buf []byte) (Patch, error) {
 		return nil, err
 	}
 
+	if err := limits.checkOperations(len(p)); err != nil {
+		return nil, err
+	}
+
+	for i, op := range p {
+		err := op.validateExtended()
+
+		if err == nil {
+			var c compiledOp
+			if c, err = compileOp(op); err == nil {
+				err = limits.checkOperation(&c)
+			}
+		}
+
+		if err != nil {
+			return nil, newPatchError(i, op, err)
+		}
+	}
+
 	return p, nil
 }
 
+func (o Operation) validate() error {
+	if err := o.checkMembers(); err != nil {
+		return err
+	}
+
+	kind := o.kind()
+	switch kind {
+	case "add", "remove", "replace", "move", "copy", "test":
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownOp, kind)
+	}
+
+	if o.Not {
+		return fmt.Errorf("%w: %s operations cannot be negated", ErrInvalidPredicate, kind)
+	}
+
+	if _, err := ParsePointer(o.path()); err != nil {
+		return err
+	}
+
+	if kind == "move" || kind == "copy" {
+		if _, err := ParsePointer(o.from()); err != nil {
+			return err
+		}
+	}
+
+	if kind == "test" && o.Value == nil {
+		return ErrMissingValue
+	}
+
+	if _, err := o.rawValue(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkMembers returns an error if a required member was missing from the
+// decoded operation.
+func (o Operation) checkMembers() error {
+	if len(o.missing) > 0 {
+		return fmt.Errorf("%w: %q", ErrMissingMember, o.missing[0])
+	}
+
+	return nil
+}
+
 // Apply mutates a JSON document according to the patch, and returns the new
 // document.
 func (p Patch) Apply(doc []byte) ([]byte, error) {
-	return p.ApplyIndent(doc, "")
+	return p.ApplyWithOptions(doc, NewApplyOptions())
 }
 
 // ApplyIndent mutates a JSON document according to the patch, and returns the new
 // document indented.
 func (p Patch) ApplyIndent(doc []byte, indent string) ([]byte, error) {
-	var pd container
-	if doc[0] == '[' {
-		pd = &partialArray{}
-	} else {
-		pd = &partialDoc{}
+	options := NewApplyOptions()
+	options.Indent = indent
+	return p.ApplyWithOptions(doc, options)
+}
+
+// ApplyWithOptions mutates a JSON document according to the patch and the
+// passed in ApplyOptions, and returns the new document. A nil options uses
+// the defaults from NewApplyOptions.
+func (p Patch) ApplyWithOptions(doc []byte, options *ApplyOptions) ([]byte, error) {
+	if options == nil {
+		options = NewApplyOptions()
 	}
 
-	err := json.Unmarshal(doc, pd)
+	return p.apply(doc, options, nil)
+}
+
+// apply mutates doc according to the patch, recording the operations
+// reverting it in undo unless nil.
+func (p Patch) apply(doc []byte, options *ApplyOptions, undo *undoLog) ([]byte, error) {
+	if err := options.Limits.checkOperations(len(p)); err != nil {
+		return nil, err
+	}
+
+	a, err := newApplier(doc, options, undo)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = nil
-
-	var accumulatedCopySize int64
-
-	for _, op := range p {
-		switch op.kind() {
-		case "add":
-			err = p.add(&pd, op)
-		case "remove":
-			err = p.remove(&pd, op)
-		case "replace":
-			err = p.replace(&pd, op)
-		case "move":
-			err = p.move(&pd, op)
-		case "test":
-			err = p.test(&pd, op)
-		case "copy":
-			err = p.copy(&pd, op, &accumulatedCopySize)
-		default:
-			err = fmt.Errorf("unexpected kind: %s", op.kind())
+	for i, op := range p {
+		c, err := compileOp(op)
+
+		if err == nil {
+			err = a.apply(&c)
 		}
 
 		if err != nil {
-			return nil, err
+			return nil, newPatchError(i, op, err)
 		}
 	}
 
-	if indent != "" {
-		return json.MarshalIndent(pd, "", indent)
-	}
-
-	return json.Marshal(pd)
-}
-
-// From http://tools.ietf.org/html/rfc6901#section-4 :
-//
-// Evaluation of each reference token begins by decoding any escaped
-// character sequence.  This is performed by first transforming any
-// occurrence of the sequence '~1' to '/', and then transforming any
-// occurrence of the sequence '~0' to '~'.
-
-var (
-	rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
-)
-
-func decodePatchKey(k string) string {
-	return rfc6901Decoder.Replace(k)
+	return a.result()
 }
@@ -2,7 +2,11 @@ package jsonpatch
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/goccy/go-json"
@@ -30,7 +34,7 @@ func applyPatch(doc, patch string) (string, error) {
 	obj, err := DecodePatch([]byte(patch))
 
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	out, err := obj.Apply([]byte(doc))
@@ -281,10 +285,6 @@ var BadCases = []BadCase{
 		`{ "foo": "bar" }`,
 		`[ { "op": "add", "pathz": "/baz", "value": "qux" } ]`,
 	},
-	{
-		`{ "foo": "bar" }`,
-		`[ { "op": "add", "path": "", "value": "qux" } ]`,
-	},
 	{
 		`{ "foo": ["bar","baz"]}`,
 		`[ { "op": "replace", "path": "/foo/2", "value": "bum"}]`,
@@ -484,10 +484,338 @@ func TestAllTest(t *testing.T) {
 		} else if !c.result && err == nil {
 			t.Errorf("Testing passed when it should have faild: %s", err)
 		} else if !c.result {
-			expected := fmt.Sprintf("testing value %s failed", c.failedPath)
-			if err.Error() != expected {
-				t.Errorf("Testing failed as expected but invalid message: expected [%s], got [%s]", expected, err)
+			var perr *PatchError
+			if !errors.As(err, &perr) || perr.Op != "test" || perr.Path != c.failedPath {
+				t.Errorf("Testing failed as expected but invalid error: expected test of %s, got [%s]", c.failedPath, err)
+			} else if !errors.Is(err, ErrTestFailed) && !errors.Is(err, ErrMissingValue) {
+				t.Errorf("Testing failed as expected but invalid cause: %s", err)
 			}
 		}
 	}
 }
+
+func TestApplyWithOptionsNegativeIndices(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[{"op":"add","path":"/foo/-1","value":"qux"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := []byte(`{"foo":["bar","baz"]}`)
+
+	out, err := patch.ApplyWithOptions(doc, &ApplyOptions{SupportNegativeIndices: true})
+	if err != nil {
+		t.Fatalf("Unable to apply patch: %s", err)
+	}
+	if !compareJSON(string(out), `{"foo":["bar","baz","qux"]}`) {
+		t.Errorf("Patch did not apply, got %s", out)
+	}
+
+	_, err = patch.ApplyWithOptions(doc, &ApplyOptions{SupportNegativeIndices: false})
+	if err == nil {
+		t.Errorf("Negative index should have been rejected")
+	}
+}
+
+func TestApplyWithOptionsCopySizeLimit(t *testing.T) {
+	doc := fmt.Sprintf(`{ "foo": ["A", %q] }`, repeatedA(48))
+	patch, err := DecodePatch([]byte(`[
+		{ "op": "copy", "path": "/foo/-", "from": "/foo/1" },
+		{ "op": "copy", "path": "/foo/-", "from": "/foo/1" }]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = patch.ApplyWithOptions([]byte(doc), &ApplyOptions{AccumulatedCopySizeLimit: 99})
+	var sizeErr *AccumulatedCopySizeError
+	if !errors.As(err, &sizeErr) {
+		t.Errorf("Expected an AccumulatedCopySizeError, got %v", err)
+	}
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded, got %v", err)
+	}
+
+	_, err = patch.ApplyWithOptions([]byte(doc), &ApplyOptions{AccumulatedCopySizeLimit: 100})
+	if err != nil {
+		t.Errorf("Unable to apply patch: %s", err)
+	}
+}
+
+func TestApplyWithOptionsIndent(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[{"op":"add","path":"/b","value":[1]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := patch.ApplyWithOptions([]byte(`{"a":1}`), &ApplyOptions{Indent: "  "})
+	if err != nil {
+		t.Fatalf("Unable to apply patch: %s", err)
+	}
+	expected := "{\n  \"a\": 1,\n  \"b\": [\n    1\n  ]\n}"
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\n\nActual:\n%s", expected, out)
+	}
+
+	out, err = patch.ApplyWithOptions([]byte(`{"a":1}`), nil)
+	if err != nil {
+		t.Fatalf("Unable to apply patch: %s", err)
+	}
+	if string(out) != `{"a":1,"b":[1]}` {
+		t.Errorf("Unexpected result %s", out)
+	}
+}
+
+func TestApplyWithOptionsConcurrent(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[{"op":"remove","path":"/foo/-1"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := []byte(`{"foo":["bar","baz"]}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(negative bool) {
+			defer wg.Done()
+			_, err := patch.ApplyWithOptions(doc, &ApplyOptions{SupportNegativeIndices: negative})
+			if negative && err != nil {
+				t.Errorf("Unable to apply patch: %s", err)
+			}
+			if !negative && err == nil {
+				t.Errorf("Negative index should have been rejected")
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func TestPatchError(t *testing.T) {
+	doc := `{"foo":["bar"],"baz":"qux"}`
+	testCases := []struct {
+		patch string
+		index int
+		op    string
+		path  string
+		from  string
+		cause error
+	}{
+		{`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/baz","value":"bar"}]`, 1, "test", "/baz", "", ErrTestFailed},
+		{`[{"op":"add","path":"/a/b","value":1}]`, 0, "add", "/a/b", "", ErrPathNotFound},
+		{`[{"op":"remove","path":"/a"}]`, 0, "remove", "/a", "", ErrPathNotFound},
+		{`[{"op":"replace","path":"/foo/1","value":1}]`, 0, "replace", "/foo/1", "", ErrInvalidIndex},
+		{`[{"op":"add","path":"/foo/x","value":1}]`, 0, "add", "/foo/x", "", ErrInvalidIndex},
+		{`[{"op":"remove","path":"/foo/-"}]`, 0, "remove", "/foo/-", "", ErrInvalidIndex},
+		{`[{"op":"move","from":"/foo/3","path":"/a"}]`, 0, "move", "/a", "/foo/3", ErrInvalidIndex},
+		{`[{"op":"copy","from":"/a/b","path":"/a"}]`, 0, "copy", "/a", "/a/b", ErrPathNotFound},
+		{`[{"op":"copy","from":"/a","path":"/b"}]`, 0, "copy", "/b", "/a", ErrPathNotFound},
+		{`[{"op":"move","from":"/a","path":"/b"}]`, 0, "move", "/b", "/a", ErrPathNotFound},
+		{`[{"op":"remove","path":"/baz"},{"op":"merge","path":"/a"}]`, 1, "merge", "/a", "", ErrUnknownOp},
+		{`[{"op":"test","path":"/baz"}]`, 0, "test", "/baz", "", ErrMissingValue},
+		{`[{"op":"copy","path":"/baz"}]`, 0, "copy", "/baz", "", ErrMissingMember},
+		{`[{"op":"add","value":1}]`, 0, "add", "", "", ErrMissingMember},
+		{`[{"path":"/baz","value":1}]`, 0, "", "/baz", "", ErrMissingMember},
+	}
+
+	for _, c := range testCases {
+		_, err := applyPatch(doc, c.patch)
+		var perr *PatchError
+		if !errors.As(err, &perr) {
+			t.Errorf("Patch %s should have failed with a PatchError, got %v", c.patch, err)
+			continue
+		}
+		if perr.Index != c.index || perr.Op != c.op || perr.Path != c.path || perr.From != c.from {
+			t.Errorf("Patch %s failed with unexpected details: %#v", c.patch, perr)
+		}
+		if !errors.Is(err, c.cause) {
+			t.Errorf("Patch %s should have failed with %v, got %v", c.patch, c.cause, err)
+		}
+	}
+}
+
+func TestPathNotFoundMessage(t *testing.T) {
+	testCases := []struct {
+		patch, path string
+	}{
+		{`[{"op":"remove","path":"/foo/x"}]`, "/foo/x"},
+		{`[{"op":"move","from":"/foo/x","path":"/y"}]`, "/foo/x"},
+		{`[{"op":"copy","from":"/foo/x","path":"/y"}]`, "/foo/x"},
+	}
+
+	for _, c := range testCases {
+		_, err := applyPatch(`{"foo":{}}`, c.patch)
+		if !errors.Is(err, ErrPathNotFound) || !strings.Contains(err.Error(), strconv.Quote(c.path)) {
+			t.Errorf("Patch %s should have failed with %q not found, got %v", c.patch, c.path, err)
+		}
+	}
+}
+
+func TestDecodePatchValidation(t *testing.T) {
+	_, err := DecodePatch([]byte(`[{"op":"add","path":"/a","value":1},{"op":"frobnicate","path":"/a"}]`))
+	var perr *PatchError
+	if !errors.As(err, &perr) || perr.Index != 1 || !errors.Is(err, ErrUnknownOp) {
+		t.Errorf("Expected an unknown operation error at index 1, got %v", err)
+	}
+
+	_, err = DecodePatch([]byte(`[{"op":"test","path":"/a"}]`))
+	if !errors.Is(err, ErrMissingValue) {
+		t.Errorf("Expected a missing value error, got %v", err)
+	}
+
+	_, err = DecodePatch([]byte(`[{"op":"add","path":"a","value":1}]`))
+	if !errors.Is(err, ErrInvalidPointer) {
+		t.Errorf("Expected a pointer error, got %v", err)
+	}
+
+	// Unknown kinds are reported by Apply as well when the patch is not
+	// decoded through DecodePatch.
+	var patch Patch
+	if err := json.Unmarshal([]byte(`[{"op":"frobnicate","path":"/a"}]`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	_, err = patch.Apply([]byte(`{}`))
+	if !errors.As(err, &perr) || perr.Op != "frobnicate" || !errors.Is(err, ErrUnknownOp) {
+		t.Errorf("Expected an unknown operation error, got %v", err)
+	}
+}
+
+func TestRootPointer(t *testing.T) {
+	testCases := []struct {
+		doc, patch, result string
+	}{
+		{`{ "foo": "bar" }`, `[ { "op": "add", "path": "", "value": "qux" } ]`, `"qux"`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"","value":{"baz":[1]}}]`, `{"baz":[1]}`},
+		{`[1,2]`, `[{"op":"remove","path":""}]`, `null`},
+		{`{"foo":"bar"}`, `[{"op":"test","path":"","value":{"foo":"bar"}}]`, `{"foo":"bar"}`},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":""}]`, `{"bar":1}`},
+		{`{"foo":1}`, `[{"op":"copy","from":"","path":"/bar"}]`, `{"bar":{"foo":1},"foo":1}`},
+		{`{"foo":1}`, `[{"op":"add","path":"/","value":2}]`, `{"":2,"foo":1}`},
+		{" \n\t\r[1]", `[{"op":"add","path":"/-","value":2}]`, `[1,2]`},
+		{` {"foo":1}`, `[]`, `{"foo":1}`},
+		{`"bar"`, `[{"op":"test","path":"","value":"bar"},{"op":"replace","path":"","value":1}]`, `1`},
+		{`3.5`, `[{"op":"add","path":"","value":true}]`, `true`},
+		{`false`, `[{"op":"copy","from":"","path":""}]`, `false`},
+		{`null`, `[{"op":"test","path":"","value":null}]`, `null`},
+		{`null`, `[{"op":"add","path":"","value":{}},{"op":"add","path":"/a","value":null}]`, `{"a":null}`},
+	}
+
+	for _, c := range testCases {
+		out, err := applyPatch(c.doc, c.patch)
+		if err != nil {
+			t.Errorf("Unable to apply patch %s to %s: %s", c.patch, c.doc, err)
+		} else if out != c.result {
+			t.Errorf("Patch %s on %s: expected %s, got %s", c.patch, c.doc, c.result, out)
+		}
+	}
+
+	badCases := []struct {
+		doc, patch string
+	}{
+		{`"bar"`, `[{"op":"add","path":"/a","value":1}]`},
+		{`null`, `[{"op":"add","path":"/a","value":1}]`},
+		{`1`, `[{"op":"test","path":"","value":2}]`},
+		{`{"foo":1}`, `[{"op":"move","from":"","path":"/bar"}]`},
+		{``, `[]`},
+		{`  `, `[]`},
+	}
+
+	for _, c := range badCases {
+		if _, err := applyPatch(c.doc, c.patch); err == nil {
+			t.Errorf("Patch %s on %q should have failed to apply but it did not", c.patch, c.doc)
+		}
+	}
+}
+
+func TestApplyWithOptionsEnsurePathExistsOnAdd(t *testing.T) {
+	testCases := []struct {
+		doc, patch, result string
+	}{
+		{`{}`, `[{"op":"add","path":"/settings/ui/theme","value":"dark"}]`, `{"settings":{"ui":{"theme":"dark"}}}`},
+		{`{"settings":{"lang":"en"}}`, `[{"op":"add","path":"/settings/ui/theme","value":"dark"}]`, `{"settings":{"lang":"en","ui":{"theme":"dark"}}}`},
+		{`{}`, `[{"op":"add","path":"/list/0/name","value":"a"}]`, `{"list":[{"name":"a"}]}`},
+		{`{}`, `[{"op":"add","path":"/list/0/-","value":1}]`, `{"list":[[1]]}`},
+		{`{}`, `[{"op":"add","path":"/list/-","value":1}]`, `{"list":[1]}`},
+		{`{"list":[{"name":"a"}]}`, `[{"op":"add","path":"/list/1/name","value":"b"}]`, `{"list":[{"name":"a"},{"name":"b"}]}`},
+		{`{"list":[null]}`, `[{"op":"add","path":"/list/0/name","value":"a"}]`, `{"list":[{"name":"a"}]}`},
+		{`{"a":null}`, `[{"op":"add","path":"/a/b","value":1}]`, `{"a":{"b":1}}`},
+		{`null`, `[{"op":"add","path":"/a~1b/c~0d","value":1}]`, `{"a/b":{"c~d":1}}`},
+		{`{"a":{}}`, `[{"op":"add","path":"/a","value":1}]`, `{"a":1}`},
+	}
+
+	for _, c := range testCases {
+		patch, err := DecodePatch([]byte(c.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := patch.ApplyWithOptions([]byte(c.doc), &ApplyOptions{EnsurePathExistsOnAdd: true})
+		if err != nil {
+			t.Errorf("Unable to apply patch %s to %s: %s", c.patch, c.doc, err)
+		} else if string(out) != c.result {
+			t.Errorf("Patch %s on %s: expected %s, got %s", c.patch, c.doc, c.result, out)
+		}
+
+		if _, err := patch.Apply([]byte(c.doc)); err == nil && c.doc != `{"a":{}}` {
+			t.Errorf("Patch %s on %s should fail without EnsurePathExistsOnAdd", c.patch, c.doc)
+		}
+	}
+
+	badCases := []struct {
+		doc, patch string
+		cause      error
+	}{
+		{`{"a":1}`, `[{"op":"add","path":"/a/b","value":1}]`, ErrPathNotFound},
+		{`{"list":[]}`, `[{"op":"add","path":"/list/2/name","value":1}]`, ErrInvalidIndex},
+		{`{}`, `[{"op":"remove","path":"/a/b"}]`, ErrPathNotFound},
+	}
+
+	for _, c := range badCases {
+		patch, err := DecodePatch([]byte(c.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = patch.ApplyWithOptions([]byte(c.doc), &ApplyOptions{EnsurePathExistsOnAdd: true})
+		if !errors.Is(err, c.cause) {
+			t.Errorf("Patch %s on %s should have failed with %v, got %v", c.patch, c.doc, c.cause, err)
+		}
+	}
+}
+
+func TestApplyArrayMutations(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	doc, err := json.Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Inserts and removes all over the array, including negative indices,
+	// checked against the same changes made to a slice.
+	patch := Patch{}
+	for i := 0; i < 300; i++ {
+		switch i % 3 {
+		case 0:
+			idx := (i * 7) % (len(items) + 1)
+			patch = append(patch, NewPatch("add", fmt.Sprintf("/%d", idx), -i))
+			items = append(items[:idx], append([]int{-i}, items[idx:]...)...)
+		case 1:
+			idx := (i * 11) % len(items)
+			patch = append(patch, NewPatch("remove", fmt.Sprintf("/%d", idx-len(items)), nil))
+			items = append(items[:idx], items[idx+1:]...)
+		default:
+			patch = append(patch, NewPatch("add", "/-", i))
+			items = append(items, i)
+		}
+	}
+
+	out, err := patch.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := json.Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(expected, out) {
+		t.Errorf("Expected %s, got %s", expected, out)
+	}
+}
@@ -0,0 +1,226 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// From http://tools.ietf.org/html/rfc6901#section-4 :
+//
+// Evaluation of each reference token begins by decoding any escaped
+// character sequence.  This is performed by first transforming any
+// occurrence of the sequence '~1' to '/', and then transforming any
+// occurrence of the sequence '~0' to '~'.
+
+var (
+	rfc6901Encoder = strings.NewReplacer("~", "~0", "/", "~1")
+	rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// Pointer is a JSON Pointer (RFC 6901) held as its unescaped reference
+// tokens. The empty Pointer references the whole document.
+type Pointer []string
+
+// EscapeToken escapes "~" and "/" in a reference token.
+func EscapeToken(token string) string {
+	return rfc6901Encoder.Replace(token)
+}
+
+// UnescapeToken decodes the "~1" and "~0" sequences of a reference token.
+func UnescapeToken(token string) string {
+	return rfc6901Decoder.Replace(token)
+}
+
+// ParsePointer parses the string representation of a JSON Pointer, which is
+// either empty or made of "/" prefixed escaped tokens.
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+
+	if s[0] != '/' {
+		return nil, fmt.Errorf("%w: %q does not start with /", ErrInvalidPointer, s)
+	}
+
+	tokens := strings.Split(s[1:], "/")
+
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("%w: %q has an invalid escape sequence", ErrInvalidPointer, s)
+			}
+		}
+		tokens[i] = UnescapeToken(token)
+	}
+
+	return Pointer(tokens), nil
+}
+
+// String returns the escaped representation of the pointer.
+func (p Pointer) String() string {
+	var sb strings.Builder
+	for _, token := range p {
+		sb.WriteByte('/')
+		sb.WriteString(EscapeToken(token))
+	}
+	return sb.String()
+}
+
+// Append returns a new pointer made of p followed by the unescaped tokens.
+func (p Pointer) Append(tokens ...string) Pointer {
+	q := make(Pointer, len(p), len(p)+len(tokens))
+	copy(q, p)
+	return append(q, tokens...)
+}
+
+// Parent returns the pointer to the container of the location of p, the
+// parent of the root pointer is the root pointer.
+func (p Pointer) Parent() Pointer {
+	if len(p) == 0 {
+		return p
+	}
+	return p[: len(p)-1 : len(p)-1]
+}
+
+// IsPrefixOf reports whether p references q or one of its ancestors.
+func (p Pointer) IsPrefixOf(q Pointer) bool {
+	if len(p) > len(q) {
+		return false
+	}
+	for i, token := range p {
+		if q[i] != token {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the value referenced by p in doc.
+func (p Pointer) Get(doc []byte) ([]byte, error) {
+	root, err := newRootDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := p.lookup(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(val)
+}
+
+// lookup returns the node referenced by p in the document held by root.
+func (p Pointer) lookup(root container) (*lazyNode, error) {
+	con, key, err := p.find(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.checkMember(con, key); err != nil {
+		return nil, err
+	}
+
+	return con.get(key)
+}
+
+// checkMember returns an error if con, the container p resolves to, is an
+// object without the member key: its get method gives nil for those.
+func (p Pointer) checkMember(con container, key string) error {
+	if pd, ok := con.(*partialDoc); ok {
+		if _, ok := (*pd)[key]; !ok {
+			return errPathNotFound(p.String())
+		}
+	}
+
+	return nil
+}
+
+// Set stores value at the location of p in doc and returns the new document.
+// Existing values are replaced, missing object members are added and the
+// "-" token appends to an array. The parent of the location must exist.
+func (p Pointer) Set(doc, value []byte) ([]byte, error) {
+	if !validJSON(value) {
+		return nil, fmt.Errorf("invalid JSON value: %s", value)
+	}
+
+	root, err := newRootDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	con, key, err := p.find(root)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(json.RawMessage, len(value))
+	copy(raw, value)
+	val := newLazyNode(&raw)
+
+	if _, ok := con.(*partialArray); ok && key != "-" {
+		err = con.set(key, val)
+	} else {
+		err = con.add(key, val, &ApplyOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(root.node)
+}
+
+// Remove deletes the value referenced by p in doc and returns the new
+// document. Removing the root leaves a null document.
+func (p Pointer) Remove(doc []byte) ([]byte, error) {
+	root, err := newRootDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	con, key, err := p.find(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.checkMember(con, key); err != nil {
+		return nil, err
+	}
+
+	err = con.remove(key, &ApplyOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(root.node)
+}
+
+// find resolves all but the last token of p in the document held by root and
+// returns the container of the last token along with it. The root pointer
+// resolves to root itself, which holds the document under the empty key.
+func (p Pointer) find(root container) (container, string, error) {
+	doc := root
+	key := ""
+
+	for _, token := range p {
+		next, err := doc.get(key)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if next == nil {
+			return nil, "", errPathNotFound(p.String())
+		}
+
+		doc, err = next.intoContainer()
+		if err != nil {
+			return nil, "", errPathNotFound(p.String())
+		}
+
+		key = token
+	}
+
+	return doc, key, nil
+}
@@ -0,0 +1,149 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6901Doc is the example document of RFC 6901 section 5.
+var rfc6901Doc = []byte(`{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8
+}`)
+
+func TestPointerRFC6901Examples(t *testing.T) {
+	testCases := []struct {
+		pointer  string
+		expected string
+	}{
+		{"", `{"":0," ":7,"a/b":1,"c%d":2,"e^f":3,"foo":["bar","baz"],"g|h":4,"i\\j":5,"k\"l":6,"m~n":8}`},
+		{"/foo", `["bar","baz"]`},
+		{"/foo/0", `"bar"`},
+		{"/", `0`},
+		{"/a~1b", `1`},
+		{"/c%d", `2`},
+		{"/e^f", `3`},
+		{"/g|h", `4`},
+		{"/i\\j", `5`},
+		{"/k\"l", `6`},
+		{"/ ", `7`},
+		{"/m~0n", `8`},
+	}
+
+	for _, tc := range testCases {
+		p, err := ParsePointer(tc.pointer)
+		require.NoError(t, err)
+		assert.Equal(t, tc.pointer, p.String())
+		value, err := p.Get(rfc6901Doc)
+		require.NoError(t, err, tc.pointer)
+		assert.True(t, Equal([]byte(tc.expected), value), "%s: expected %s got %s", tc.pointer, tc.expected, value)
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	p, err := ParsePointer("/a~1b/~01/0")
+	require.NoError(t, err)
+	assert.Equal(t, Pointer{"a/b", "~1", "0"}, p)
+	assert.Equal(t, "/a~1b/~01/0", p.String())
+
+	p, err = ParsePointer("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(p))
+
+	for _, s := range []string{"a", "/a~", "/a~2", "#/a"} {
+		_, err := ParsePointer(s)
+		assert.True(t, errors.Is(err, ErrInvalidPointer), s)
+	}
+}
+
+func TestPointerTokens(t *testing.T) {
+	assert.Equal(t, "a~1b~0c", EscapeToken("a/b~c"))
+	assert.Equal(t, "a/b~c", UnescapeToken("a~1b~0c"))
+	assert.Equal(t, "~1", UnescapeToken("~01"))
+
+	p := Pointer{"a"}
+	q := p.Append("b/c", "0")
+	assert.Equal(t, "/a/b~1c/0", q.String())
+	assert.Equal(t, Pointer{"a"}, p)
+	assert.Equal(t, "/a/b~1c", q.Parent().String())
+	assert.Equal(t, "", Pointer{}.Parent().String())
+	assert.Equal(t, "/a/x", q.Parent().Parent().Append("x").String())
+	assert.Equal(t, "/a/b~1c/0", q.String())
+
+	assert.True(t, Pointer{}.IsPrefixOf(q))
+	assert.True(t, p.IsPrefixOf(q))
+	assert.True(t, q.IsPrefixOf(q))
+	assert.False(t, q.IsPrefixOf(p))
+	assert.False(t, Pointer{"b"}.IsPrefixOf(q))
+}
+
+func TestPointerSetRemove(t *testing.T) {
+	doc := []byte(`{"foo":["bar","baz"],"a":{"b":1}}`)
+	testCases := []struct {
+		pointer, value, expected string
+	}{
+		{"/foo/0", `"qux"`, `{"a":{"b":1},"foo":["qux","baz"]}`},
+		{"/foo/-", `"qux"`, `{"a":{"b":1},"foo":["bar","baz","qux"]}`},
+		{"/a/c", `[1]`, `{"a":{"b":1,"c":[1]},"foo":["bar","baz"]}`},
+		{"/a/b", `null`, `{"a":{"b":null},"foo":["bar","baz"]}`},
+		{"", `true`, `true`},
+		{"/a", `{ }`, `{"a":{},"foo":["bar","baz"]}`},
+		{"/a/c", `{"d":{ }}`, `{"a":{"b":1,"c":{"d":{}}},"foo":["bar","baz"]}`},
+	}
+	for _, tc := range testCases {
+		p, err := ParsePointer(tc.pointer)
+		require.NoError(t, err)
+		out, err := p.Set(doc, []byte(tc.value))
+		require.NoError(t, err, tc.pointer)
+		assert.Equal(t, tc.expected, string(out), tc.pointer)
+	}
+
+	out, err := Pointer{"foo", "0"}.Remove(doc)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":{"b":1},"foo":["baz"]}`, string(out))
+	out, err = Pointer{"a"}.Remove(doc)
+	require.NoError(t, err)
+	assert.Equal(t, `{"foo":["bar","baz"]}`, string(out))
+	out, err = Pointer{}.Remove(doc)
+	require.NoError(t, err)
+	assert.Equal(t, `null`, string(out))
+
+	_, err = Pointer{"x", "y"}.Set(doc, []byte(`1`))
+	assert.True(t, errors.Is(err, ErrPathNotFound))
+	_, err = Pointer{"foo", "2"}.Set(doc, []byte(`1`))
+	assert.True(t, errors.Is(err, ErrInvalidIndex))
+	_, err = Pointer{"a"}.Set(doc, []byte(`{`))
+	assert.Error(t, err)
+	_, err = Pointer{"a"}.Set(doc, []byte(`1 2`))
+	assert.Error(t, err)
+	_, err = Pointer{"x"}.Remove(doc)
+	assert.True(t, errors.Is(err, ErrPathNotFound))
+	_, err = Pointer{"x"}.Get(doc)
+	assert.True(t, errors.Is(err, ErrPathNotFound))
+	_, err = Pointer{"foo", "2"}.Get(doc)
+	assert.True(t, errors.Is(err, ErrInvalidIndex))
+	_, err = Pointer{"a", "b", "c"}.Get(doc)
+	assert.True(t, errors.Is(err, ErrPathNotFound))
+}
+
+func TestCreatePatchEscapedPaths(t *testing.T) {
+	a := []byte(`{"":{"a":1},"b/c":{"d~e":[1]}}`)
+	b := []byte(`{"":{"a":2},"b/c":{"d~e":[1,2]}}`)
+	patch, err := CreatePatch(a, b)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patch))
+	assert.Equal(t, "//a", patch[0].Path)
+	assert.Equal(t, "/b~1c/d~0e/-", patch[1].Path)
+	assert.True(t, Equal(b, applyOperations(t, a, patch)))
+}
@@ -0,0 +1,242 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"regexp"
+
+	"github.com/goccy/go-json"
+)
+
+// predicate is the condition checked by an extension operation:
+//   - "exists" and "absent" on the presence of a value at the path;
+//   - "type" on its JSON type, one of "null", "boolean", "number",
+//     "string", "array" and "object";
+//   - "matches" on a string matching a regular expression;
+//   - "range" on a number within {"min": ..., "max": ...}, both bounds
+//     being inclusive and optional;
+//   - "contains" on an array holding an element equal to the value.
+type predicate struct {
+	kind     string
+	typ      string
+	re       *regexp.Regexp
+	min, max *big.Float
+	value    json.RawMessage
+}
+
+var jsonTypes = map[string]bool{
+	"null": true, "boolean": true, "number": true, "string": true, "array": true, "object": true,
+}
+
+// isPredicate tells if kind is the kind of an extension operation.
+func isPredicate(kind string) bool {
+	switch kind {
+	case "exists", "absent", "type", "matches", "range", "contains":
+		return true
+	}
+	return false
+}
+
+// parsePredicate parses the value raw of an extension operation of the given
+// kind.
+func parsePredicate(kind string, raw json.RawMessage) (*predicate, error) {
+	p := &predicate{kind: kind}
+
+	switch kind {
+	case "exists", "absent":
+		return p, nil
+	}
+
+	if raw == nil {
+		return nil, ErrMissingValue
+	}
+
+	switch kind {
+	case "type":
+		if err := json.Unmarshal(raw, &p.typ); err != nil || !jsonTypes[p.typ] {
+			return nil, fmt.Errorf("%w: unknown type %s", ErrInvalidPredicate, raw)
+		}
+	case "matches":
+		var expr string
+		if err := json.Unmarshal(raw, &expr); err != nil {
+			return nil, fmt.Errorf("%w: %s is not a string", ErrInvalidPredicate, raw)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPredicate, err)
+		}
+		p.re = re
+	case "range":
+		var bounds struct {
+			Min json.RawMessage `json:"min"`
+			Max json.RawMessage `json:"max"`
+		}
+		if err := json.Unmarshal(raw, &bounds); err != nil || (bounds.Min == nil && bounds.Max == nil) {
+			return nil, fmt.Errorf("%w: %s is not a range", ErrInvalidPredicate, raw)
+		}
+		var ok bool
+		if p.min, ok = parseNumber(bounds.Min); !ok {
+			return nil, fmt.Errorf("%w: %s is not a range", ErrInvalidPredicate, raw)
+		}
+		if p.max, ok = parseNumber(bounds.Max); !ok {
+			return nil, fmt.Errorf("%w: %s is not a range", ErrInvalidPredicate, raw)
+		}
+	case "contains":
+		p.value = raw
+	}
+
+	return p, nil
+}
+
+// parseNumber parses the number literal raw, a nil raw giving a nil number.
+func parseNumber(raw []byte) (*big.Float, bool) {
+	if raw == nil {
+		return nil, true
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || (raw[0] != '-' && (raw[0] < '0' || raw[0] > '9')) {
+		return nil, false
+	}
+
+	f, _, err := big.ParseFloat(string(raw), 10, 1024, big.ToNearestEven)
+	return f, err == nil
+}
+
+// validatePredicate checks an extension operation like validate.
+func (o Operation) validatePredicate() error {
+	if err := o.checkMembers(); err != nil {
+		return err
+	}
+
+	if _, err := ParsePointer(o.path()); err != nil {
+		return err
+	}
+
+	raw, err := o.rawValue()
+	if err != nil {
+		return err
+	}
+
+	_, err = parsePredicate(o.kind(), raw)
+	return err
+}
+
+// validateExtended checks op like validate, accepting the extension
+// operations as well as the negated "test" operations.
+func (o Operation) validateExtended() error {
+	if isPredicate(o.kind()) {
+		return o.validatePredicate()
+	}
+
+	if o.kind() == "test" {
+		o.Not = false
+	}
+
+	return o.validate()
+}
+
+// predicate applies an extension operation, the predicates other than
+// "exists" and "absent" failing on a missing value whether negated or not.
+func (a *applier) predicate(op *compiledOp) error {
+	val, err := op.path.lookup(a.doc)
+
+	var ok bool
+	switch op.Op {
+	case "exists":
+		ok = err == nil
+	case "absent":
+		ok = err != nil
+	default:
+		if err != nil {
+			return err
+		}
+		ok = a.match(op.pred, val)
+	}
+
+	if ok == op.Not {
+		return fmt.Errorf("%w: %s predicate at %q", ErrTestFailed, op.Op, op.Path)
+	}
+
+	return nil
+}
+
+// match tells if val satisfies p.
+func (a *applier) match(p *predicate, val *lazyNode) bool {
+	switch p.kind {
+	case "type":
+		return typeOf(val) == p.typ
+	case "matches":
+		var s string
+		return typeOf(val) == "string" && decodeNode(val, &s) && p.re.MatchString(s)
+	case "range":
+		var n json.Number
+		if typeOf(val) != "number" || !decodeNode(val, &n) {
+			return false
+		}
+		f, ok := parseNumber([]byte(n))
+		return ok && (p.min == nil || f.Cmp(p.min) >= 0) && (p.max == nil || f.Cmp(p.max) <= 0)
+	case "contains":
+		if val == nil || (val.which != eAry && !val.tryAry()) {
+			return false
+		}
+		for _, elem := range val.ary {
+			if a.equal(elem, newValueNode(p.value)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// typeOf returns the JSON type of val.
+func typeOf(val *lazyNode) string {
+	if val == nil {
+		return "null"
+	}
+
+	switch val.which {
+	case eDoc:
+		return "object"
+	case eAry:
+		return "array"
+	}
+
+	if val.raw == nil {
+		return "null"
+	}
+
+	raw := bytes.TrimSpace(*val.raw)
+	if len(raw) == 0 {
+		return "null"
+	}
+
+	switch raw[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+
+	return "number"
+}
+
+// decodeNode decodes val into v, a json.Number for numbers.
+func decodeNode(val *lazyNode, v interface{}) bool {
+	buf, err := json.Marshal(val)
+	if err != nil {
+		return false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	return dec.Decode(v) == nil
+}
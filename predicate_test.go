@@ -0,0 +1,209 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredicates(t *testing.T) {
+	doc := `{"name":"John","age":42,"ratio":0.5,"tags":["a",{"b":1},null],"address":{"city":"Springfield"},"none":null}`
+
+	testCases := []struct {
+		op   string
+		pass bool
+	}{
+		{`{"op":"exists","path":"/address/city"}`, true},
+		{`{"op":"exists","path":"/none"}`, true},
+		{`{"op":"exists","path":"/tags/2"}`, true},
+		{`{"op":"exists","path":"/address/zip"}`, false},
+		{`{"op":"exists","path":"/tags/3"}`, false},
+		{`{"op":"exists","path":"/address/zip","not":true}`, true},
+		{`{"op":"absent","path":"/missing/child"}`, true},
+		{`{"op":"absent","path":"/tags/-"}`, true},
+		{`{"op":"absent","path":"/name"}`, false},
+		{`{"op":"absent","path":"/name","not":true}`, true},
+		{`{"op":"type","path":"/name","value":"string"}`, true},
+		{`{"op":"type","path":"/age","value":"number"}`, true},
+		{`{"op":"type","path":"/tags","value":"array"}`, true},
+		{`{"op":"type","path":"/tags/1","value":"object"}`, true},
+		{`{"op":"type","path":"/none","value":"null"}`, true},
+		{`{"op":"type","path":"","value":"object"}`, true},
+		{`{"op":"type","path":"/age","value":"string"}`, false},
+		{`{"op":"type","path":"/age","value":"string","not":true}`, true},
+		{`{"op":"matches","path":"/name","value":"^J[a-z]+$"}`, true},
+		{`{"op":"matches","path":"/address/city","value":"field$"}`, true},
+		{`{"op":"matches","path":"/name","value":"^j"}`, false},
+		{`{"op":"matches","path":"/age","value":"42"}`, false},
+		{`{"op":"matches","path":"/name","value":"^j","not":true}`, true},
+		{`{"op":"range","path":"/age","value":{"min":18,"max":65}}`, true},
+		{`{"op":"range","path":"/age","value":{"min":42}}`, true},
+		{`{"op":"range","path":"/age","value":{"max":4.2e1}}`, true},
+		{`{"op":"range","path":"/ratio","value":{"min":0,"max":1}}`, true},
+		{`{"op":"range","path":"/age","value":{"max":41.999}}`, false},
+		{`{"op":"range","path":"/name","value":{"min":0}}`, false},
+		{`{"op":"range","path":"/age","value":{"min":50},"not":true}`, true},
+		{`{"op":"contains","path":"/tags","value":"a"}`, true},
+		{`{"op":"contains","path":"/tags","value":{"b":1}}`, true},
+		{`{"op":"contains","path":"/tags","value":null}`, true},
+		{`{"op":"contains","path":"/tags","value":"b"}`, false},
+		{`{"op":"contains","path":"/name","value":"J"}`, false},
+		{`{"op":"contains","path":"/tags","value":"b","not":true}`, true},
+		{`{"op":"test","path":"/age","value":42,"not":true}`, false},
+		{`{"op":"test","path":"/age","value":41,"not":true}`, true},
+	}
+
+	options := NewApplyOptions()
+	options.Predicates = true
+
+	for _, tc := range testCases {
+		patch, err := DecodePatch([]byte("[" + tc.op + "]"))
+		require.NoError(t, err, tc.op)
+
+		out, err := patch.ApplyWithOptions([]byte(doc), options)
+		if tc.pass {
+			if assert.NoError(t, err, tc.op) {
+				assert.True(t, Equal([]byte(doc), out), tc.op)
+			}
+			continue
+		}
+		assert.True(t, errors.Is(err, ErrTestFailed), "%s: %v", tc.op, err)
+	}
+
+	// The other predicates fail on a missing value, negated or not.
+	for _, op := range []string{
+		`{"op":"type","path":"/address/zip","value":"null","not":true}`,
+		`{"op":"contains","path":"/missing","value":1,"not":true}`,
+	} {
+		patch, err := DecodePatch([]byte("[" + op + "]"))
+		require.NoError(t, err)
+		_, err = patch.ApplyWithOptions([]byte(doc), options)
+		assert.True(t, errors.Is(err, ErrPathNotFound), "%s: %v", op, err)
+	}
+}
+
+// Guarded updates apply as a whole, or not at all.
+func TestPredicatesGuard(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[
+		{"op":"type","path":"/version","value":"number"},
+		{"op":"absent","path":"/locked"},
+		{"op":"replace","path":"/version","value":2},
+		{"op":"add","path":"/locked","value":true}
+	]`))
+	require.NoError(t, err)
+
+	options := NewApplyOptions()
+	options.Predicates = true
+
+	out, err := patch.ApplyWithOptions([]byte(`{"version":1}`), options)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":2,"locked":true}`, string(out))
+
+	_, err = patch.ApplyWithOptions(out, options)
+	var perr *PatchError
+	require.True(t, errors.As(err, &perr), "%v", err)
+	assert.Equal(t, 1, perr.Index)
+	assert.Equal(t, "absent", perr.Op)
+
+	compiled, err := patch.Compile()
+	require.NoError(t, err)
+	_, err = compiled.ApplyWithOptions(out, options)
+	assert.True(t, errors.Is(err, ErrTestFailed))
+
+	d, err := NewDocument(out)
+	require.NoError(t, err)
+	err = d.ApplyWithOptions(patch, options)
+	assert.True(t, errors.Is(err, ErrTestFailed))
+
+	// Without the option, the extension operations are unknown.
+	_, err = patch.Apply([]byte(`{"version":1}`))
+	assert.True(t, errors.Is(err, ErrUnknownOp), "%v", err)
+}
+
+// A negated test is an extension operation, unknown without the option.
+func TestPredicatesNegatedTest(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[{"op":"test","path":"/a","value":1,"not":true}]`))
+	require.NoError(t, err)
+
+	_, err = patch.Apply([]byte(`{"a":1}`))
+	assert.True(t, errors.Is(err, ErrUnknownOp), "%v", err)
+	_, err = patch.Apply([]byte(`{"a":2}`))
+	assert.True(t, errors.Is(err, ErrUnknownOp), "%v", err)
+
+	options := NewApplyOptions()
+	options.Predicates = true
+	_, err = patch.ApplyWithOptions([]byte(`{"a":1}`), options)
+	assert.True(t, errors.Is(err, ErrTestFailed), "%v", err)
+
+	compiled, err := patch.Compile()
+	require.NoError(t, err)
+	_, err = compiled.ApplyWithOptions([]byte(`{"a":1}`), options)
+	assert.True(t, errors.Is(err, ErrTestFailed), "%v", err)
+	_, err = compiled.ApplyWithOptions([]byte(`{"a":2}`), options)
+	assert.NoError(t, err)
+}
+
+func TestPredicatesInvalid(t *testing.T) {
+	testCases := []struct {
+		op    string
+		cause error
+	}{
+		{`{"op":"type","path":"/a"}`, ErrMissingValue},
+		{`{"op":"type","path":"/a","value":"integer"}`, ErrInvalidPredicate},
+		{`{"op":"type","path":"/a","value":1}`, ErrInvalidPredicate},
+		{`{"op":"matches","path":"/a","value":"("}`, ErrInvalidPredicate},
+		{`{"op":"matches","path":"/a","value":["a"]}`, ErrInvalidPredicate},
+		{`{"op":"range","path":"/a","value":{}}`, ErrInvalidPredicate},
+		{`{"op":"range","path":"/a","value":{"min":"1"}}`, ErrInvalidPredicate},
+		{`{"op":"range","path":"/a","value":[1,2]}`, ErrInvalidPredicate},
+		{`{"op":"contains","path":"/a"}`, ErrMissingValue},
+		{`{"op":"exists","path":"a"}`, ErrInvalidPointer},
+	}
+
+	for _, tc := range testCases {
+		_, err := DecodePatch([]byte("[" + tc.op + "]"))
+		var perr *PatchError
+		require.True(t, errors.As(err, &perr), "%s: %v", tc.op, err)
+		assert.True(t, errors.Is(err, tc.cause), "%s: %v", tc.op, err)
+	}
+
+	// The tools working on RFC 6902 patches reject them, and negated tests.
+	_, err := Compose(Patch{NewPatch("exists", "/a", nil)})
+	assert.True(t, errors.Is(err, ErrUnknownOp), "%v", err)
+	_, err = Compose(Patch{{Op: "test", Path: "/a", Value: 1, Not: true}})
+	assert.True(t, errors.Is(err, ErrInvalidPredicate), "%v", err)
+
+	// Only the operations made in Go can negate the other kinds, which
+	// reject it.
+	_, err = Patch{{Op: "add", Path: "/a", Value: 1, Not: true}}.Apply([]byte(`{}`))
+	assert.True(t, errors.Is(err, ErrInvalidPredicate), "%v", err)
+}
+
+// "not" is an unknown member of the RFC 6902 operations other than "test",
+// ignored whatever its value.
+func TestPredicatesNotMember(t *testing.T) {
+	for _, op := range []string{
+		`{"op":"add","path":"/a","value":1,"not":"x"}`,
+		`{"op":"add","path":"/a","value":1,"not":true}`,
+		`{"op":"replace","path":"/a","value":1,"not":{"a":[1]}}`,
+	} {
+		patch, err := DecodePatch([]byte("[" + op + "]"))
+		require.NoError(t, err, op)
+		assert.False(t, patch[0].Not, op)
+		out, err := patch.Apply([]byte(`{}`))
+		require.NoError(t, err, op)
+		assert.Equal(t, `{"a":1}`, string(out))
+	}
+}
+
+func TestPredicatesMarshal(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[{"op":"matches","path":"/a","value":"^x","not":true},{"op":"exists","path":"/b"}]`))
+	require.NoError(t, err)
+	assert.True(t, patch[0].Not)
+
+	out, err := MarshalPatch(patch)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"op":"matches","path":"/a","value":"^x","not":true},{"op":"exists","path":"/b"}]`, string(out))
+}
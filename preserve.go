@@ -0,0 +1,278 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/goccy/go-json"
+)
+
+// rawItem is a member of an object, or an element of an array, as written in
+// a document.
+type rawItem struct {
+	// key is the decoded name of a member.
+	key string
+	// before is the text between the opening bracket or the previous comma
+	// and the item, name the name of a member as written and colon the colon
+	// following it with its surrounding spaces.
+	before, name, colon []byte
+	// after is the text between the value and the next comma.
+	after []byte
+}
+
+// scanContainer returns the items of the object or array raw along with the
+// text preceding its closing bracket, ok is false if raw is not a container.
+func scanContainer(raw []byte) (items []rawItem, closing []byte, ok bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < 2 || (raw[0] != '{' && raw[0] != '[') {
+		return nil, nil, false
+	}
+	object := raw[0] == '{'
+
+	i := 1
+	for {
+		start := i
+		i = skipSpaces(raw, i)
+		if i >= len(raw) {
+			return nil, nil, false
+		}
+		if raw[i] == '}' || raw[i] == ']' {
+			if len(items) > 0 {
+				// A trailing comma.
+				return nil, nil, false
+			}
+			return items, raw[start:i], true
+		}
+
+		item := rawItem{before: raw[start:i]}
+
+		if object {
+			end := scanValue(raw, i)
+			if end < 0 || raw[i] != '"' {
+				return nil, nil, false
+			}
+			item.name = raw[i:end]
+			if err := json.Unmarshal(item.name, &item.key); err != nil {
+				return nil, nil, false
+			}
+
+			i = skipSpaces(raw, end)
+			if i >= len(raw) || raw[i] != ':' {
+				return nil, nil, false
+			}
+			i = skipSpaces(raw, i+1)
+			item.colon = raw[end:i]
+		}
+
+		end := scanValue(raw, i)
+		if end < 0 {
+			return nil, nil, false
+		}
+
+		i = skipSpaces(raw, end)
+		if i >= len(raw) {
+			return nil, nil, false
+		}
+		item.after = raw[end:i]
+		items = append(items, item)
+
+		switch raw[i] {
+		case ',':
+			i++
+		case '}', ']':
+			// The text preceding the closing bracket is not kept with the
+			// last item, which might be removed.
+			items[len(items)-1].after = nil
+			return items, raw[end:i], true
+		default:
+			return nil, nil, false
+		}
+	}
+}
+
+func skipSpaces(raw []byte, i int) int {
+	for i < len(raw) {
+		switch raw[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// scanValue returns the end of the value starting at i in raw, -1 if there
+// is none.
+func scanValue(raw []byte, i int) int {
+	if i >= len(raw) {
+		return -1
+	}
+
+	switch raw[i] {
+	case '"':
+		for j := i + 1; j < len(raw); j++ {
+			switch raw[j] {
+			case '\\':
+				j++
+			case '"':
+				return j + 1
+			}
+		}
+		return -1
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(raw); j++ {
+			switch raw[j] {
+			case '"':
+				end := scanValue(raw, j)
+				if end < 0 {
+					return -1
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return -1
+	}
+
+	j := i
+	for j < len(raw) && bytes.IndexByte([]byte(" \t\n\r,]}"), raw[j]) < 0 {
+		j++
+	}
+	if j == i {
+		return -1
+	}
+	return j
+}
+
+// writePreserving writes n to buf, copying the text the values not decoded
+// were read from. The objects and arrays decoded by the operations are written
+// with the member order and the spacing of their original text, new members
+// being appended in the style of the last one.
+func writePreserving(buf *bytes.Buffer, n *lazyNode) error {
+	if n == nil {
+		buf.WriteString("null")
+		return nil
+	}
+
+	switch n.which {
+	case eDoc:
+		return writeObject(buf, n)
+	case eAry:
+		return writeArray(buf, n)
+	}
+
+	if n.raw == nil {
+		buf.WriteString("null")
+		return nil
+	}
+
+	buf.Write(*n.raw)
+	return nil
+}
+
+func writeObject(buf *bytes.Buffer, n *lazyNode) error {
+	var items []rawItem
+	var closing []byte
+	if n.raw != nil {
+		items, closing, _ = scanContainer(*n.raw)
+	}
+
+	style := rawItem{colon: []byte(":")}
+	if len(items) > 0 {
+		style = items[len(items)-1]
+	}
+
+	buf.WriteByte('{')
+
+	written := make(map[string]bool, len(n.doc))
+	write := func(item rawItem, val *lazyNode) error {
+		if len(written) > 0 {
+			buf.WriteByte(',')
+		}
+		written[item.key] = true
+		buf.Write(item.before)
+		buf.Write(item.name)
+		buf.Write(item.colon)
+		if err := writePreserving(buf, val); err != nil {
+			return err
+		}
+		buf.Write(item.after)
+		return nil
+	}
+
+	for _, item := range items {
+		val, ok := n.doc[item.key]
+		if !ok || written[item.key] {
+			continue
+		}
+		if err := write(item, val); err != nil {
+			return err
+		}
+	}
+
+	added := make([]string, 0, len(n.doc)-len(written))
+	for key := range n.doc {
+		if !written[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+
+	for _, key := range added {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		item := rawItem{key: key, before: style.before, name: name, colon: style.colon}
+		if err := write(item, n.doc[key]); err != nil {
+			return err
+		}
+	}
+
+	buf.Write(closing)
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeArray(buf *bytes.Buffer, n *lazyNode) error {
+	var items []rawItem
+	var closing []byte
+	if n.raw != nil {
+		items, closing, _ = scanContainer(*n.raw)
+	}
+
+	buf.WriteByte('[')
+
+	for i, val := range n.ary {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		style := rawItem{}
+		if i < len(items) {
+			style = items[i]
+		} else if len(items) > 0 {
+			style = items[len(items)-1]
+		}
+
+		buf.Write(style.before)
+		if err := writePreserving(buf, val); err != nil {
+			return err
+		}
+		if i < len(n.ary)-1 {
+			buf.Write(style.after)
+		}
+	}
+
+	buf.Write(closing)
+	buf.WriteByte(']')
+	return nil
+}
@@ -0,0 +1,108 @@
+package jsonpatch
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreserveFormatting(t *testing.T) {
+	doc := "{\n  \"z\": 1.50,\n  \"a\" : {\"x\":  1e3, \"y\": [1, 2,\n 3]},\n  \"s\": \"\\u00e9\",\n  \"e\": { }\n}\n"
+
+	testCases := []struct {
+		patch    string
+		expected string
+	}{
+		{
+			`[{"op":"test","path":"/a/y","value":[1,2,3]},{"op":"test","path":"/e","value":{}}]`,
+			doc,
+		},
+		{
+			`[{"op":"replace","path":"/a/x","value":2}]`,
+			"{\n  \"z\": 1.50,\n  \"a\" : {\"x\":  2, \"y\": [1, 2,\n 3]},\n  \"s\": \"\\u00e9\",\n  \"e\": { }\n}\n",
+		},
+		{
+			`[{"op":"add","path":"/new","value":{"k": 1}},{"op":"add","path":"/b","value":true}]`,
+			"{\n  \"z\": 1.50,\n  \"a\" : {\"x\":  1e3, \"y\": [1, 2,\n 3]},\n  \"s\": \"\\u00e9\",\n  \"e\": { },\n  \"b\": true,\n  \"new\": {\"k\": 1}\n}\n",
+		},
+		{
+			`[{"op":"remove","path":"/e"},{"op":"remove","path":"/z"}]`,
+			"{\n  \"a\" : {\"x\":  1e3, \"y\": [1, 2,\n 3]},\n  \"s\": \"\\u00e9\"\n}\n",
+		},
+		{
+			`[{"op":"add","path":"/a/y/1","value":9},{"op":"remove","path":"/a/y/0"},{"op":"add","path":"/a/y/-","value":4}]`,
+			"{\n  \"z\": 1.50,\n  \"a\" : {\"x\":  1e3, \"y\": [9, 2,\n 3,\n 4]},\n  \"s\": \"\\u00e9\",\n  \"e\": { }\n}\n",
+		},
+		{
+			`[{"op":"move","from":"/a/y","path":"/e/y"},{"op":"replace","path":"/s","value":"\u00e8"}]`,
+			"{\n  \"z\": 1.50,\n  \"a\" : {\"x\":  1e3},\n  \"s\": \"\\u00e8\",\n  \"e\": {\"y\":[1, 2,\n 3] }\n}\n",
+		},
+		{
+			`[{"op":"replace","path":"","value":[1, 2]}]`,
+			"[1, 2]\n",
+		},
+	}
+
+	options := NewApplyOptions()
+	options.PreserveFormatting = true
+	options.Indent = "  "
+
+	for _, tc := range testCases {
+		patch, err := DecodePatch([]byte(tc.patch))
+		require.NoError(t, err)
+
+		out, err := patch.ApplyWithOptions([]byte(doc), options)
+		require.NoError(t, err, tc.patch)
+		assert.Equal(t, tc.expected, string(out), tc.patch)
+
+		compiled, err := patch.Compile()
+		require.NoError(t, err)
+		out, err = compiled.ApplyWithOptions([]byte(doc), options)
+		require.NoError(t, err, tc.patch)
+		assert.Equal(t, tc.expected, string(out), tc.patch)
+	}
+}
+
+func TestPreserveFormattingEnsurePathExists(t *testing.T) {
+	options := NewApplyOptions()
+	options.PreserveFormatting = true
+	options.EnsurePathExistsOnAdd = true
+
+	patch := Patch{NewPatch("add", "/b/c/-", 1)}
+	out, err := patch.ApplyWithOptions([]byte("{ \"a\": 0 }"), options)
+	require.NoError(t, err)
+	assert.Equal(t, `{ "a": 0, "b": {"c":[1]} }`, string(out))
+}
+
+// The documents given with PreserveFormatting are the ones given without.
+func TestPreserveFormattingCases(t *testing.T) {
+	options := NewApplyOptions()
+	options.PreserveFormatting = true
+
+	for _, c := range Cases {
+		patch, err := DecodePatch([]byte(c.patch))
+		require.NoError(t, err)
+
+		expected, err := patch.Apply([]byte(c.doc))
+		require.NoError(t, err)
+		out, err := patch.ApplyWithOptions([]byte(c.doc), options)
+		require.NoError(t, err, c.patch)
+		assert.True(t, Equal(expected, out), "%s: expected %s got %s", c.patch, expected, out)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	doc := []byte("{\n  \"a\": [1, [2, 3], {\"k0\": 4}],\n  \"b\": {\"k1\": [5, 6], \"k2\": 7},\n  \"c\": [8, 9, 10]\n}")
+	for i := 0; i < 500; i++ {
+		patch := randomOps(r, doc, 1+r.Intn(4))
+
+		expected, err := patch.Apply(doc)
+		if err != nil {
+			continue
+		}
+		out, err := patch.ApplyWithOptions(doc, options)
+		require.NoError(t, err, "%v", patch)
+		require.True(t, Equal(expected, out), "%v: expected %s got %s", patch, expected, out)
+	}
+}
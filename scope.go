@@ -0,0 +1,242 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/goccy/go-json"
+)
+
+// Filter returns the operations of p changing the value at prefix in doc, the
+// document p applies to. The operations within prefix are kept, the ones
+// outside of it are dropped along with the "test" operations outside of it.
+//
+// Operations crossing the boundary of prefix are converted using the values
+// of doc as patched so far: a "move" or a "copy" from outside of prefix
+// becomes an "add" of the value, a "move" to outside of prefix becomes a
+// "remove", and the ones writing an ancestor of prefix, or shifting it in an
+// array, become an "add", a "replace" or a "remove" of prefix.
+func (p Patch) Filter(doc []byte, prefix string) (Patch, error) {
+	scope, err := ParsePointer(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &scoped{prefix: scope, doc: doc}
+	out := Patch{}
+
+	for i, op := range p {
+		if err := op.validate(); err != nil {
+			return nil, newPatchError(i, op, err)
+		}
+
+		s.index = i
+		ops, err := s.filter(op)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ops...)
+	}
+
+	return out, nil
+}
+
+// Reroot returns p with the prefix removed from its locations, all of which
+// must be within prefix: "/users/42/name" becomes "/name" for the prefix
+// "/users/42".
+func (p Patch) Reroot(prefix string) (Patch, error) {
+	scope, err := ParsePointer(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(Patch, len(p))
+
+	for i, op := range p {
+		if err := op.validate(); err != nil {
+			return nil, newPatchError(i, op, err)
+		}
+
+		relative := func(s string) (string, error) {
+			p := mustPointer(s)
+			if !scope.IsPrefixOf(p) {
+				return "", fmt.Errorf("%w: %q is not within %q", ErrInvalidPointer, s, prefix)
+			}
+			return p[len(scope):].String(), nil
+		}
+
+		path, err := relative(op.path())
+		if err != nil {
+			return nil, newPatchError(i, op, err)
+		}
+
+		from := ""
+		if kind := op.kind(); kind == "move" || kind == "copy" {
+			if from, err = relative(op.from()); err != nil {
+				return nil, newPatchError(i, op, err)
+			}
+		}
+
+		out[i] = newOperation(op.kind(), from, path, op.value())
+	}
+
+	return out, nil
+}
+
+// Mount returns p applying to the value at prefix, its locations being
+// prefixed: "/name" becomes "/users/42/name" for the prefix "/users/42".
+func (p Patch) Mount(prefix string) (Patch, error) {
+	scope, err := ParsePointer(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(Patch, len(p))
+
+	for i, op := range p {
+		if err := op.validate(); err != nil {
+			return nil, newPatchError(i, op, err)
+		}
+
+		from := ""
+		if kind := op.kind(); kind == "move" || kind == "copy" {
+			from = scope.Append(mustPointer(op.from())...).String()
+		}
+
+		out[i] = newOperation(op.kind(), from, scope.Append(mustPointer(op.path())...).String(), op.value())
+	}
+
+	return out, nil
+}
+
+// scoped filters the operations of a patch for a prefix, keeping track of the
+// document they apply to.
+type scoped struct {
+	prefix Pointer
+	// index is the position of the operation being filtered.
+	index int
+	// doc is the document the pending operations apply to, the first of them
+	// being at position start in the patch.
+	doc     []byte
+	pending Patch
+	start   int
+}
+
+// skip records op as applied, without needing the document so far.
+func (s *scoped) skip(op Operation) {
+	if len(s.pending) == 0 {
+		s.start = s.index
+	}
+	s.pending = append(s.pending, op)
+}
+
+// state returns the document as patched so far.
+func (s *scoped) state() ([]byte, error) {
+	if len(s.pending) > 0 {
+		doc, err := s.pending.Apply(s.doc)
+		if err != nil {
+			return nil, reindex(err, s.start)
+		}
+		s.doc, s.pending = doc, nil
+	}
+	return s.doc, nil
+}
+
+// reindex returns err with the index of the operation failing in a patch
+// starting at position start.
+func reindex(err error, start int) error {
+	var perr *PatchError
+	if errors.As(err, &perr) {
+		perr.Index += start
+	}
+	return err
+}
+
+// filter returns the operations standing for op within the prefix.
+func (s *scoped) filter(op Operation) (Patch, error) {
+	kind := op.kind()
+	path := mustPointer(op.path())
+	within := func(p Pointer) bool {
+		return s.prefix.IsPrefixOf(p) && len(p) > len(s.prefix)
+	}
+	outside := func(p Pointer) bool {
+		return independent(p, s.prefix)
+	}
+
+	switch kind {
+	case "test":
+		s.skip(op)
+		if within(path) || equalPointers(path, s.prefix) {
+			return Patch{op}, nil
+		}
+		return nil, nil
+	case "add", "replace", "remove":
+		if within(path) {
+			s.skip(op)
+			return Patch{op}, nil
+		}
+		if outside(path) {
+			s.skip(op)
+			return nil, nil
+		}
+	case "move", "copy":
+		from := mustPointer(op.from())
+		switch {
+		case within(from) && within(path):
+			s.skip(op)
+			return Patch{op}, nil
+		case outside(from) && outside(path), kind == "copy" && within(from) && outside(path):
+			s.skip(op)
+			return nil, nil
+		case within(from) && outside(path):
+			s.skip(op)
+			return Patch{newOperation("remove", "", op.from(), nil)}, nil
+		case within(path) && (outside(from) || kind == "copy"):
+			doc, err := s.state()
+			if err != nil {
+				return nil, err
+			}
+			value, err := from.Get(doc)
+			if err != nil {
+				return nil, newPatchError(s.index, op, err)
+			}
+			s.skip(op)
+			raw := json.RawMessage(value)
+			return Patch{newOperation("add", "", op.path(), newLazyNode(&raw))}, nil
+		}
+	}
+
+	return s.replace(op)
+}
+
+// replace returns the operation setting the value at prefix as op does.
+func (s *scoped) replace(op Operation) (Patch, error) {
+	doc, err := s.state()
+	if err != nil {
+		return nil, err
+	}
+	before, beforeErr := s.prefix.Get(doc)
+
+	if doc, err = (Patch{op}).Apply(doc); err != nil {
+		return nil, reindex(err, s.index)
+	}
+	s.doc = doc
+	after, afterErr := s.prefix.Get(doc)
+
+	prefix := s.prefix.String()
+	raw := json.RawMessage(after)
+
+	switch {
+	case beforeErr == nil && afterErr == nil:
+		if Equal(before, after) {
+			return nil, nil
+		}
+		return Patch{newOperation("replace", "", prefix, newLazyNode(&raw))}, nil
+	case beforeErr == nil:
+		return Patch{newOperation("remove", "", prefix, nil)}, nil
+	case afterErr == nil:
+		return Patch{newOperation("add", "", prefix, newLazyNode(&raw))}, nil
+	}
+
+	return nil, nil
+}
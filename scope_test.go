@@ -0,0 +1,183 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	doc := `{"users":{"41":{"name":"Ann"},"42":{"name":"Bob","tags":["a"]}},"archive":{"tags":["b"]},"list":[{"n":0},{"n":1}]}`
+	testCases := []struct {
+		name     string
+		prefix   string
+		patch    string
+		expected string
+	}{
+		{
+			name:     "within",
+			prefix:   "/users/42",
+			patch:    `[{"op":"replace","path":"/users/42/name","value":"Rob"},{"op":"test","path":"/users/42/name","value":"Rob"},{"op":"add","path":"/users/42/tags/-","value":"c"}]`,
+			expected: `[{"op":"replace","path":"/users/42/name","value":"Rob"},{"op":"test","path":"/users/42/name","value":"Rob"},{"op":"add","path":"/users/42/tags/-","value":"c"}]`,
+		},
+		{
+			name:     "outside",
+			prefix:   "/users/42",
+			patch:    `[{"op":"replace","path":"/users/41/name","value":"Anna"},{"op":"test","path":"/archive","value":{"tags":["b"]}},{"op":"copy","from":"/users/41","path":"/archive/user"}]`,
+			expected: `[]`,
+		},
+		{
+			name:     "move and copy across",
+			prefix:   "/users/42",
+			patch:    `[{"op":"move","from":"/archive/tags/0","path":"/users/42/tags/0"},{"op":"copy","from":"/users/41/name","path":"/users/42/friend"},{"op":"move","from":"/users/42/name","path":"/archive/name"}]`,
+			expected: `[{"op":"add","path":"/users/42/tags/0","value":"b"},{"op":"add","path":"/users/42/friend","value":"Ann"},{"op":"remove","path":"/users/42/name"}]`,
+		},
+		{
+			name:     "ancestors",
+			prefix:   "/users/42",
+			patch:    `[{"op":"replace","path":"/users","value":{"42":{"name":"Eve"}}},{"op":"add","path":"/users/43","value":{}},{"op":"remove","path":"/users"},{"op":"add","path":"/users","value":{"42":{}}}]`,
+			expected: `[{"op":"replace","path":"/users/42","value":{"name":"Eve"}},{"op":"remove","path":"/users/42"},{"op":"add","path":"/users/42","value":{}}]`,
+		},
+		{
+			name:     "moved prefix",
+			prefix:   "/users/42",
+			patch:    `[{"op":"move","from":"/users/42","path":"/archive/user"},{"op":"copy","from":"/archive","path":"/users/42"}]`,
+			expected: `[{"op":"remove","path":"/users/42"},{"op":"add","path":"/users/42","value":{"tags":["b"],"user":{"name":"Bob","tags":["a"]}}}]`,
+		},
+		{
+			name:     "shifted element",
+			prefix:   "/list/1",
+			patch:    `[{"op":"replace","path":"/list/1/n","value":2},{"op":"add","path":"/list/0","value":{"n":3}},{"op":"replace","path":"/list/0/n","value":4}]`,
+			expected: `[{"op":"replace","path":"/list/1/n","value":2},{"op":"replace","path":"/list/1","value":{"n":0}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := DecodePatch([]byte(tc.patch))
+			require.NoError(t, err)
+			filtered, err := patch.Filter([]byte(doc), tc.prefix)
+			require.NoError(t, err)
+			out, err := json.Marshal(filtered)
+			require.NoError(t, err)
+			assert.True(t, Equal([]byte(tc.expected), out), "expected %s got %s", tc.expected, out)
+		})
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[{"op":"add","path":"/a","value":1},{"op":"remove","path":"/b"},{"op":"copy","from":"/b","path":"/s/c"}]`))
+	require.NoError(t, err)
+
+	_, err = patch.Filter([]byte(`{"a":0,"s":{}}`), "/s")
+	var perr *PatchError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 1, perr.Index)
+	assert.True(t, errors.Is(err, ErrPathNotFound))
+
+	_, err = patch.Filter([]byte(`{}`), "s")
+	assert.True(t, errors.Is(err, ErrInvalidPointer))
+}
+
+func TestRerootMount(t *testing.T) {
+	patch, err := DecodePatch([]byte(`[{"op":"replace","path":"/users/42/name","value":"Rob"},{"op":"move","from":"/users/42/a~1b","path":"/users/42/c"},{"op":"remove","path":"/users/42"}]`))
+	require.NoError(t, err)
+
+	rerooted, err := patch.Reroot("/users/42")
+	require.NoError(t, err)
+	out, err := json.Marshal(rerooted)
+	require.NoError(t, err)
+	assert.True(t, Equal([]byte(`[{"op":"replace","path":"/name","value":"Rob"},{"op":"move","from":"/a~1b","path":"/c"},{"op":"remove","path":""}]`), out), "got %s", out)
+
+	mounted, err := rerooted.Mount("/users/42")
+	require.NoError(t, err)
+	out, err = json.Marshal(mounted)
+	require.NoError(t, err)
+	expected, err := json.Marshal(patch)
+	require.NoError(t, err)
+	assert.True(t, Equal(expected, out), "expected %s got %s", expected, out)
+
+	_, err = patch.Reroot("/users/41")
+	var perr *PatchError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 0, perr.Index)
+	assert.True(t, errors.Is(err, ErrInvalidPointer))
+
+	ops, err := Patch{NewPatch("add", "/users/42/tags/-", "a")}.Reroot("/users/42")
+	require.NoError(t, err)
+	assert.Equal(t, `{"op":"add","path":"/tags/-","value":"a"}`, ops[0].JSON())
+
+	ops, err = ops.Mount("/users/4~12")
+	require.NoError(t, err)
+	assert.Equal(t, `{"op":"add","path":"/users/4~12/tags/-","value":"a"}`, ops[0].JSON())
+}
+
+// pointersOf returns the locations of the values in doc.
+func pointersOf(t *testing.T, doc []byte) []Pointer {
+	var v interface{}
+	require.NoError(t, json.Unmarshal(doc, &v))
+
+	pointers := []Pointer{}
+	var walk func(p Pointer, v interface{})
+	walk = func(p Pointer, v interface{}) {
+		pointers = append(pointers, p)
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				walk(p.Append(k), e)
+			}
+		case []interface{}:
+			for i, e := range v {
+				walk(p.Append(fmt.Sprint(i)), e)
+			}
+		}
+	}
+	walk(Pointer{}, v)
+
+	return pointers
+}
+
+// A filtered and rerooted patch turns the value at the prefix into its new
+// value.
+func TestFilterFixtures(t *testing.T) {
+	pairs := [][]string{
+		{simpleA, simpleB}, {simpleA, simpleE}, {simpleA, simpleG},
+		{complexBase, complexA}, {complexBase, complexB}, {complexBase, complexC},
+		{hyperComplexBase, hyperComplexA},
+		{superComplexBase, superComplexA},
+		{subArray5_current, subArray5__target},
+		{movesBase, `{"user":{"name":"Jane"},"archive":{"address":{"street":"Main St","number":42,"city":"Springfield"},"name":"John"}}`},
+	}
+
+	for i, pair := range pairs {
+		for _, opts := range []DiffOptions{{}, {DetectMovesAndCopies: true}} {
+			a, b := []byte(pair[0]), []byte(pair[1])
+			patch, err := CreatePatchWithOptions(a, b, opts)
+			require.NoError(t, err)
+
+			for _, prefix := range pointersOf(t, a) {
+				t.Run(fmt.Sprint(i, prefix), func(t *testing.T) {
+					filtered, err := patch.Filter(a, prefix.String())
+					require.NoError(t, err)
+					rerooted, err := filtered.Reroot(prefix.String())
+					require.NoError(t, err)
+
+					before, err := prefix.Get(a)
+					require.NoError(t, err)
+					out, err := rerooted.Apply(before)
+					require.NoError(t, err)
+
+					after, err := prefix.Get(b)
+					if err != nil {
+						after = []byte("null")
+					}
+					assert.True(t, Equal(after, out), "expected %s got %s", after, out)
+				})
+			}
+		}
+	}
+}
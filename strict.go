@@ -0,0 +1,164 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/goccy/go-json"
+)
+
+// checkStrict enforces the requirements of RFC 6902 and RFC 6901 the
+// operations are lenient about: the value of "add", "replace" and "test"
+// must be given, the locations read by an operation must exist, a value
+// cannot be moved into one of its children, and array indices are written
+// without sign nor leading zero.
+func (a *applier) checkStrict(op *compiledOp) error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.value == nil {
+			return ErrMissingValue
+		}
+	}
+
+	if op.Op == "move" && op.from.IsPrefixOf(op.path) && len(op.from) < len(op.path) {
+		return fmt.Errorf("%w: %q is a child of %q", ErrInvalidMove, op.Path, op.From)
+	}
+
+	switch op.Op {
+	case "move", "copy":
+		if err := checkIndices(a.doc, op.from, false); err != nil {
+			return err
+		}
+		if _, err := op.from.lookup(a.doc); err != nil {
+			return err
+		}
+	}
+
+	switch op.Op {
+	case "add", "move", "copy":
+		return checkIndices(a.doc, op.path, true)
+	case "remove", "replace", "test":
+		if err := checkIndices(a.doc, op.path, false); err != nil {
+			return err
+		}
+		_, err := op.path.lookup(a.doc)
+		return err
+	}
+
+	return nil
+}
+
+// checkIndices returns an error if a token of p referencing an array element
+// is not an index, "-" being accepted as the last token when end is true. The
+// tokens past a location not found are not checked.
+func checkIndices(root container, p Pointer, end bool) error {
+	doc := root
+	key := ""
+
+	for i, token := range p {
+		next, err := doc.get(key)
+		if err != nil || next == nil {
+			return nil
+		}
+
+		doc, err = next.intoContainer()
+		if err != nil {
+			return nil
+		}
+
+		if _, ok := doc.(*partialArray); ok && !isIndex(token) && !(end && token == "-" && i == len(p)-1) {
+			return errInvalidIndex(token)
+		}
+
+		key = token
+	}
+
+	return nil
+}
+
+// isIndex tells if token is an array index as defined by RFC 6901: "0" or
+// digits not starting with "0".
+func isIndex(token string) bool {
+	if token == "" || (token[0] == '0' && len(token) > 1) {
+		return false
+	}
+
+	for i := 0; i < len(token); i++ {
+		if token[i] < '0' || token[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equalValues tells if a and b are equal as defined by RFC 6902 for "test"
+// operations, which compares numbers by their values and strings once
+// unescaped.
+func equalValues(a, b *lazyNode) bool {
+	var values [2]interface{}
+
+	for i, n := range []*lazyNode{a, b} {
+		buf, err := json.Marshal(n)
+		if err != nil {
+			return false
+		}
+
+		if values[i], err = decodeValue(buf); err != nil {
+			return false
+		}
+	}
+
+	return sameValue(values[0], values[1])
+}
+
+func sameValue(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		return ok && sameNumber(a, b)
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !sameValue(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !sameValue(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a == b
+}
+
+func sameNumber(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+
+	x, _, err := big.ParseFloat(string(a), 10, 1024, big.ToNearestEven)
+	if err != nil {
+		return false
+	}
+
+	y, _, err := big.ParseFloat(string(b), 10, 1024, big.ToNearestEven)
+	if err != nil {
+		return false
+	}
+
+	return x.Cmp(y) == 0
+}
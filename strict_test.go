@@ -0,0 +1,148 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// strictCases are taken from, or written after, the json-patch-tests suite:
+// an empty expected document means that the patch must fail.
+var strictCases = []struct {
+	comment  string
+	doc      string
+	patch    string
+	expected string
+}{
+	{"empty patch", `{"foo":1}`, `[]`, `{"foo":1}`},
+	{"toplevel array", `[]`, `[{"op":"add","path":"/0","value":"foo"}]`, `["foo"]`},
+	{"add, / target", `{}`, `[{"op":"add","path":"/","value":1}]`, `{"":1}`},
+	{"add, /foo/ deep target (trailing slash)", `{"foo":{}}`, `[{"op":"add","path":"/foo/","value":1}]`, `{"foo":{"":1}}`},
+	{"add into composite value", `{"foo":1,"baz":[{"qux":"hello"}]}`, `[{"op":"add","path":"/baz/0/foo","value":"world"}]`, `{"foo":1,"baz":[{"qux":"hello","foo":"world"}]}`},
+	{"add with bad index", `{"bar":[1,2]}`, `[{"op":"add","path":"/bar/8","value":"5"}]`, ``},
+	{"add with negative index", `{"bar":[1,2]}`, `[{"op":"add","path":"/bar/-1","value":"5"}]`, ``},
+	{"add with signed index", `[1]`, `[{"op":"add","path":"/+1","value":2}]`, ``},
+	{"add with bad number", `["foo","sil"]`, `[{"op":"add","path":"/1e0","value":"bar"}]`, ``},
+	{"add to the end", `[1]`, `[{"op":"add","path":"/-","value":2}]`, `[1,2]`},
+	{"add to a missing parent", `{}`, `[{"op":"add","path":"/a/b","value":1}]`, ``},
+	{"null value should be valid obj property", `{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+	{"null value should be valid obj property to be replaced", `{"foo":null}`, `[{"op":"replace","path":"/foo","value":"truthy"}]`, `{"foo":"truthy"}`},
+	{"null value should be valid obj property to be moved", `{"foo":null}`, `[{"op":"move","from":"/foo","path":"/bar"}]`, `{"bar":null}`},
+	{"null value should still be valid obj property replace other value", `{"foo":"bar"}`, `[{"op":"replace","path":"/foo","value":null}]`, `{"foo":null}`},
+	{"test should pass despite rearrangement", `{"foo":{"foo":1,"bar":2}}`, `[{"op":"test","path":"/foo","value":{"bar":2,"foo":1}}]`, `{"foo":{"foo":1,"bar":2}}`},
+	{"test should pass despite (nested) rearrangement", `{"foo":[{"foo":1,"bar":2}]}`, `[{"op":"test","path":"/foo","value":[{"bar":2,"foo":1}]}]`, `{"foo":[{"foo":1,"bar":2}]}`},
+	{"numbers are equal by value", `{"a":1,"b":[1e3]}`, `[{"op":"test","path":"/a","value":1.0},{"op":"test","path":"/b","value":[1000]}]`, `{"a":1,"b":[1e3]}`},
+	{"numbers differing by value", `{"a":1}`, `[{"op":"test","path":"/a","value":1.000000000000000000001}]`, ``},
+	{"strings are equal once unescaped", `{"a":"A"}`, `[{"op":"test","path":"/a","value":"\u0041"}]`, `{"a":"A"}`},
+	{"~ escape ordering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+	{"comparing strings and numbers", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``},
+	{"test against implementation-specific numeric parsing", `{"1e0":"foo"}`, `[{"op":"test","path":"/1e0","value":"foo"}]`, `{"1e0":"foo"}`},
+	{"test with bad array number that has leading zeros", `["foo","bar"]`, `[{"op":"test","path":"/00","value":"foo"}]`, ``},
+	{"test with bad array number that has leading zeros", `["foo","bar"]`, `[{"op":"test","path":"/01","value":"bar"}]`, ``},
+	{"test of the end of an array", `[1]`, `[{"op":"test","path":"/-","value":1}]`, ``},
+	{"test of a missing member", `{"a":1}`, `[{"op":"test","path":"/b","value":null}]`, ``},
+	{"replace whole document", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":{"baz":"qux"}}]`, `{"baz":"qux"}`},
+	{"replace of a missing member", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"qux"}]`, ``},
+	{"replace op should fail with missing parent key", `{"foo":"bar"}`, `[{"op":"replace","path":"/lorem/ipsum","value":1}]`, ``},
+	{"replace with bad number", `[""]`, `[{"op":"replace","path":"/1e0","value":false}]`, ``},
+	{"replace with leading zeros", `[1,2]`, `[{"op":"replace","path":"/01","value":3}]`, ``},
+	{"test remove on array", `[1,2,3,4]`, `[{"op":"remove","path":"/0"}]`, `[2,3,4]`},
+	{"test repeated removes", `[1,2,3,4]`, `[{"op":"remove","path":"/1"},{"op":"remove","path":"/2"}]`, `[1,3]`},
+	{"test remove with bad index", `[1,2,3,4]`, `[{"op":"remove","path":"/1e0"}]`, ``},
+	{"remove of the end of an array", `[1]`, `[{"op":"remove","path":"/-"}]`, ``},
+	{"removing nonexistent field", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ``},
+	{"removing deep nonexistent path", `{"foo":"bar"}`, `[{"op":"remove","path":"/missing1/missing2"}]`, ``},
+	{"removing nonexistent index", `["foo","bar"]`, `[{"op":"remove","path":"/2"}]`, ``},
+	{"move to same location has no effect", `{"foo":1}`, `[{"op":"move","from":"/foo","path":"/foo"}]`, `{"foo":1}`},
+	{"move into own child", `{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, ``},
+	{"move into own child in an array", `[[1],[2]]`, `[{"op":"move","from":"/0","path":"/0/0"}]`, ``},
+	{"move from the end of an array", `[1,2]`, `[{"op":"move","from":"/-","path":"/0"}]`, ``},
+	{"test move with bad number", `{"foo":1,"baz":[1,2,3,4]}`, `[{"op":"move","from":"/baz/1e0","path":"/foo"}]`, ``},
+	{"missing from location to move", `{"foo":1}`, `[{"op":"move","from":"/bar","path":"/foo"}]`, ``},
+	{"copy to the end of an array", `{"a":1,"b":[]}`, `[{"op":"copy","from":"/a","path":"/b/-"}]`, `{"a":1,"b":[1]}`},
+	{"test copy with bad number", `{"baz":[1,2,3],"bar":1}`, `[{"op":"copy","from":"/baz/1e0","path":"/boo"}]`, ``},
+	{"missing from location to copy", `{"foo":1}`, `[{"op":"copy","from":"/bar","path":"/foo"}]`, ``},
+	{"missing 'value' parameter to add", `[1]`, `[{"op":"add","path":"/-"}]`, ``},
+	{"missing 'value' parameter to replace", `[1]`, `[{"op":"replace","path":"/0"}]`, ``},
+	{"missing 'value' parameter to test", `[null]`, `[{"op":"test","path":"/0"}]`, ``},
+	{"missing 'value' parameter to test - where undef is falsy", `[false]`, `[{"op":"test","path":"/0"}]`, ``},
+	{"missing 'path' parameter", `{}`, `[{"op":"add","value":"bar"}]`, ``},
+	{"invalid JSON Pointer token", `{}`, `[{"op":"add","path":"foo","value":"bar"}]`, ``},
+	{"missing 'from' parameter to copy", `[1]`, `[{"op":"copy","path":"/-"}]`, ``},
+	{"unrecognized op should fail", `{"foo":1}`, `[{"op":"spam","path":"/foo","value":1}]`, ``},
+	{"spurious patch properties", `{"foo":1}`, `[{"op":"test","path":"/foo","value":1,"spurious":1}]`, `{"foo":1}`},
+	{"patch with different capitalisation than doc", `{"foo":"bar"}`, `[{"op":"add","path":"/FOO","value":"BAR"}]`, `{"foo":"bar","FOO":"BAR"}`},
+}
+
+func TestStrict(t *testing.T) {
+	options := NewApplyOptions()
+	options.Strict = true
+	options.SupportNegativeIndices = true
+	options.EnsurePathExistsOnAdd = true
+
+	for _, tc := range strictCases {
+		var patch Patch
+		require.NoError(t, json.Unmarshal([]byte(tc.patch), &patch), tc.comment)
+
+		out, err := patch.ApplyWithOptions([]byte(tc.doc), options)
+		if tc.expected == "" {
+			assert.Error(t, err, "%s: got %s", tc.comment, out)
+			continue
+		}
+		if assert.NoError(t, err, tc.comment) {
+			assert.True(t, Equal([]byte(tc.expected), out), "%s: expected %s got %s", tc.comment, tc.expected, out)
+		}
+	}
+}
+
+// The leniencies of the default mode are kept.
+func TestStrictLenient(t *testing.T) {
+	testCases := []struct {
+		doc      string
+		patch    Patch
+		expected string
+	}{
+		{`[1,2]`, Patch{NewPatch("replace", "/01", 3)}, `[1,3]`},
+		{`[1,2]`, Patch{NewPatch("remove", "/-1", nil)}, `[1]`},
+		{`{"a":1}`, Patch{NewPatch("replace", "/b", 2)}, `{"a":1,"b":2}`},
+		{`{"a":1}`, Patch{NewPatch("add", "/b", nil)}, `{"a":1,"b":null}`},
+		{`[[1],[2]]`, Patch{{Op: "move", From: "/0", Path: "/0/0"}}, `[[[1],2]]`},
+	}
+
+	for _, tc := range testCases {
+		out, err := tc.patch.Apply([]byte(tc.doc))
+		require.NoError(t, err, "%v", tc.patch)
+		assert.True(t, Equal([]byte(tc.expected), out), "%v: expected %s got %s", tc.patch, tc.expected, out)
+
+		options := NewApplyOptions()
+		options.Strict = true
+		_, err = tc.patch.ApplyWithOptions([]byte(tc.doc), options)
+		assert.Error(t, err, "%v", tc.patch)
+	}
+}
+
+func TestStrictErrors(t *testing.T) {
+	testCases := []struct {
+		doc   string
+		patch Patch
+		cause error
+	}{
+		{`[1,2]`, Patch{NewPatch("replace", "/01", 3)}, ErrInvalidIndex},
+		{`{"a":1}`, Patch{NewPatch("replace", "/b", 2)}, ErrPathNotFound},
+		{`{"a":1}`, Patch{NewPatch("add", "/b", nil)}, ErrMissingValue},
+		{`{"a":{}}`, Patch{{Op: "move", From: "/a", Path: "/a/b"}}, ErrInvalidMove},
+	}
+
+	options := NewApplyOptions()
+	options.Strict = true
+
+	for _, tc := range testCases {
+		_, err := tc.patch.ApplyWithOptions([]byte(tc.doc), options)
+		var perr *PatchError
+		require.True(t, errors.As(err, &perr), "%v: %v", tc.patch, err)
+		assert.True(t, errors.Is(err, tc.cause), "%v: %v", tc.patch, err)
+	}
+}
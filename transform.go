@@ -0,0 +1,427 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Transform rebases two concurrent patches made against the same document,
+// following the rules of operational transformation: a' is a transformed to
+// apply after b, and b' is b transformed to apply after a, so that applying
+// a then b' gives the same document as b then a'.
+//
+// The document is not known, so tokens made of digits are assumed to be array
+// indices and "-" an append to an array: the indices of an operation are
+// shifted by the insertions and removals of the other patch, and an element
+// inserted by a at the same index as one inserted by b ends up first.
+// Identical operations are applied once.
+//
+// A *ConflictError is returned when the operations touch the same location in
+// ways that cannot be reconciled: both set it, one sets or removes a value
+// the other one changes within, both append to the same array, or one
+// changes a value that the other one tests or copies.
+func Transform(a, b Patch) (Patch, Patch, error) {
+	for _, patch := range []Patch{a, b} {
+		for i, op := range patch {
+			if err := op.validateTransform(); err != nil {
+				return nil, nil, newPatchError(i, op, err)
+			}
+		}
+	}
+
+	at, bt, cerr := transform(a, b, false)
+	if cerr != nil {
+		return nil, nil, cerr
+	}
+
+	return at, bt, nil
+}
+
+// transform implements Transform on validated patches. When ordered is set,
+// only b' is meant to be used, so that elements appended by both patches
+// to the same array do not conflict, the ones of a coming first, and
+// identical insertions are applied once.
+func transform(a, b Patch, ordered bool) (Patch, Patch, *ConflictError) {
+	// Transformed operations of b, done once applied by an identical
+	// operation of a.
+	bs := make(Patch, len(b))
+	copy(bs, b)
+	done := make([]bool, len(b))
+
+	as := Patch{}
+
+	for i, op := range a {
+		x, applied := op, false
+		for j, y := range bs {
+			if done[j] {
+				continue
+			}
+
+			if sameOp(x, y) || (ordered && isInsertion(x) && sameChange(x, y)) {
+				applied, done[j] = true, true
+				break
+			}
+
+			if ordered && appendsTo(x, y) {
+				continue
+			}
+
+			xt, ok := transformOp(x, y, false)
+			if !ok {
+				return nil, nil, &ConflictError{A: i, B: j, Path: x.path()}
+			}
+
+			yt, ok := transformOp(y, x, true)
+			if !ok {
+				return nil, nil, &ConflictError{A: i, B: j, Path: y.path()}
+			}
+
+			x, bs[j] = xt, yt
+		}
+
+		if !applied {
+			as = append(as, x)
+		}
+	}
+
+	bt := Patch{}
+	for j, op := range bs {
+		if !done[j] {
+			bt = append(bt, op)
+		}
+	}
+
+	return as, bt, nil
+}
+
+// appendsTo reports whether a and b both append to the same array.
+func appendsTo(a, b Operation) bool {
+	if !isInsertion(a) || !isInsertion(b) {
+		return false
+	}
+	p, q := mustPointer(a.path()), mustPointer(b.path())
+	return p[len(p)-1] == "-" && equalPointers(p, q)
+}
+
+// validateTransform checks op like validate, rejecting negative indices as
+// they cannot be transformed without the document.
+func (o Operation) validateTransform() error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	for _, p := range pointers(o) {
+		for _, token := range p {
+			if i, err := strconv.Atoi(token); err == nil && i < 0 {
+				return fmt.Errorf("%w: %s", ErrInvalidIndex, token)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameOp reports whether a and b make the same change, so that applying
+// both is applying one of them. Two insertions in an array are different.
+func sameOp(a, b Operation) bool {
+	return !isInsertion(a) && sameChange(a, b)
+}
+
+// sameChange reports whether a and b are the same operation, other than
+// "test".
+func sameChange(a, b Operation) bool {
+	kind := a.kind()
+	if kind != b.kind() || kind == "test" || a.path() != b.path() {
+		return false
+	}
+
+	switch kind {
+	case "move", "copy":
+		return a.from() == b.from()
+	case "add", "replace":
+		av, bv := a.value(), b.value()
+		if av == nil || bv == nil {
+			return av == bv
+		}
+		return av.equal(bv)
+	}
+
+	return true
+}
+
+// isInsertion reports whether op inserts an element in an array.
+func isInsertion(op Operation) bool {
+	switch op.kind() {
+	case "add", "copy", "move":
+		path := mustPointer(op.path())
+		if len(path) == 0 {
+			return false
+		}
+		last := path[len(path)-1]
+		_, ok := indexOf(last)
+		return ok || last == "-"
+	}
+	return false
+}
+
+// transformOp returns x as it applies after o. It is not ok when o changes
+// a value x depends on.
+func transformOp(x, o Operation, shiftTies bool) (Operation, bool) {
+	kind := x.kind()
+	path := mustPointer(x.path())
+	gap := isInsertion(x)
+
+	if kind == "test" && readConflict(path, o) {
+		return Operation{}, false
+	}
+
+	var from Pointer
+
+	switch kind {
+	case "copy":
+		from = mustPointer(x.from())
+		if readConflict(from, o) {
+			return Operation{}, false
+		}
+		var ok bool
+		if from, ok = transformPointer(from, false, shiftTies, o); !ok {
+			return Operation{}, false
+		}
+	case "move":
+		from = mustPointer(x.from())
+		if o.kind() == "move" && equalPointers(from, mustPointer(o.from())) {
+			// Both move the same value.
+			return Operation{}, false
+		}
+
+		var ok bool
+		if from, ok = transformPointer(from, false, shiftTies, o); !ok {
+			return Operation{}, false
+		}
+
+		if equalPointers(mustPointer(x.from()), path) {
+			return newOperation(kind, from.String(), from.String(), nil), true
+		}
+
+		// The destination of a move is relative to the document without the
+		// moved value, and so must be the operation it is transformed against.
+		removal := newOperation("remove", "", x.from(), nil)
+		rebased, ok := transformOp(o, removal, !shiftTies)
+		if !ok {
+			// o reads or writes within the moved value, only what it does
+			// elsewhere may shift the destination.
+			if rebased, ok = outside(o, removal, !shiftTies); !ok {
+				return newOperation(kind, from.String(), x.path(), nil), true
+			}
+		}
+		o = rebased
+	}
+
+	path, ok := transformPointer(path, gap, shiftTies, o)
+	if !ok {
+		return Operation{}, false
+	}
+
+	return newOperation(kind, from.String(), path.String(), x.value()), true
+}
+
+// outside returns an operation standing for what op does out of the location
+// removed by removal, false if nothing.
+func outside(op, removal Operation, shiftTies bool) (Operation, bool) {
+	kind := op.kind()
+	if kind != "add" && kind != "copy" && kind != "move" {
+		return Operation{}, false
+	}
+
+	// The destination of a move is relative to the document without the value
+	// it moves.
+	dest := removal
+	if kind == "move" {
+		var ok bool
+		if dest, ok = transformOp(removal, newOperation("remove", "", op.from(), nil), shiftTies); !ok {
+			return Operation{}, false
+		}
+	}
+
+	if path, ok := transformPointer(mustPointer(op.path()), isInsertion(op), shiftTies, dest); ok {
+		return newOperation("add", "", path.String(), nil), true
+	}
+
+	if kind == "move" {
+		if from, ok := transformPointer(mustPointer(op.from()), false, shiftTies, removal); ok {
+			return newOperation("remove", "", from.String(), nil), true
+		}
+	}
+
+	return Operation{}, false
+}
+
+// transformPointer returns p, a location used by an operation concurrent to
+// op, as it is after op applies. gap is set when p is the position an element
+// is inserted at, and shiftTies when such a position goes after an element
+// inserted at the same index by op. It is not ok when op sets or removes the
+// value at p or one of its ancestors.
+func transformPointer(p Pointer, gap, shiftTies bool, op Operation) (Pointer, bool) {
+	switch op.kind() {
+	case "add", "copy":
+		return insertPointer(p, gap, shiftTies, mustPointer(op.path()))
+	case "remove":
+		q, _, ok := deletePointer(p, gap, mustPointer(op.path()), nil)
+		return q, ok
+	case "replace":
+		return setPointer(p, gap, mustPointer(op.path()))
+	case "move":
+		from, to := mustPointer(op.from()), mustPointer(op.path())
+		if equalPointers(from, to) {
+			return p, true
+		}
+		q, relocated, ok := deletePointer(p, gap, from, to)
+		if !ok || relocated {
+			return q, ok
+		}
+		return insertPointer(q, gap, shiftTies, to)
+	}
+
+	return p, true
+}
+
+// insertPointer transforms p against the addition of a value at t.
+func insertPointer(p Pointer, gap, shiftTies bool, t Pointer) (Pointer, bool) {
+	if len(t) == 0 {
+		return setPointer(p, gap, t)
+	}
+
+	c, last := t.Parent(), t[len(t)-1]
+	atGap := gap && len(p) == len(t)
+
+	if last == "-" {
+		if atGap && c.IsPrefixOf(p) && p[len(c)] == "-" {
+			// Concurrent appends would be in a different order on each side.
+			return nil, false
+		}
+		return p, true
+	}
+
+	k, ok := indexOf(last)
+	if !ok {
+		return setPointer(p, gap, t)
+	}
+
+	if len(p) <= len(c) || !c.IsPrefixOf(p) {
+		return p, true
+	}
+
+	i, ok := indexOf(p[len(c)])
+	if !ok {
+		return p, true
+	}
+
+	if i > k || (i == k && (!atGap || shiftTies)) {
+		return withIndex(p, len(c), i+1), true
+	}
+
+	return p, true
+}
+
+// deletePointer transforms p against the removal of the value at f, which is
+// relocated to `to` by a move unless nil. relocated is set when p is within
+// the moved value.
+func deletePointer(p Pointer, gap bool, f, to Pointer) (q Pointer, relocated bool, ok bool) {
+	if len(f) == 0 {
+		return relocate(p, f, to)
+	}
+
+	c, last := f.Parent(), f[len(f)-1]
+
+	k, isIndex := indexOf(last)
+	if !isIndex {
+		if f.IsPrefixOf(p) {
+			return relocate(p, f, to)
+		}
+		return p, false, true
+	}
+
+	if len(p) <= len(c) || !c.IsPrefixOf(p) {
+		return p, false, true
+	}
+
+	i, ok := indexOf(p[len(c)])
+	if !ok {
+		return p, false, true
+	}
+
+	switch {
+	case i > k:
+		return withIndex(p, len(c), i-1), false, true
+	case i == k && !(gap && len(p) == len(f)):
+		return relocate(p, f, to)
+	}
+
+	return p, false, true
+}
+
+func relocate(p, f, to Pointer) (Pointer, bool, bool) {
+	if to == nil || hasAppendToken(to) {
+		return nil, false, false
+	}
+	return to.Append(p[len(f):]...), true, true
+}
+
+// setPointer transforms p against setting the value at s.
+func setPointer(p Pointer, gap bool, s Pointer) (Pointer, bool) {
+	if !s.IsPrefixOf(p) {
+		return p, true
+	}
+
+	// Inserting before an element does not depend on its value.
+	if gap && len(p) == len(s) && len(s) > 0 {
+		if _, ok := indexOf(s[len(s)-1]); ok {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+// readConflict reports whether op changes the value read at p, or within it.
+// Changes of the value at p itself are reported by transformPointer.
+func readConflict(p Pointer, op Operation) bool {
+	switch op.kind() {
+	case "add", "copy", "remove", "replace":
+		return changes(p, mustPointer(op.path()))
+	case "move":
+		from := mustPointer(op.from())
+		if changes(p, from) {
+			return true
+		}
+		// The destination is relative to the document without the moved value.
+		q, _, ok := deletePointer(p, false, from, mustPointer(op.path()))
+		return ok && changes(q, mustPointer(op.path()))
+	}
+
+	return false
+}
+
+// changes reports whether writing at w changes the value within p.
+func changes(p, w Pointer) bool {
+	if len(w) > 0 {
+		if _, ok := indexOf(w[len(w)-1]); ok || w[len(w)-1] == "-" {
+			// The content of the array changes, the elements are shifted.
+			return p.IsPrefixOf(w.Parent())
+		}
+	}
+	return len(p) < len(w) && p.IsPrefixOf(w)
+}
+
+// indexOf returns the array index held by token, if any.
+func indexOf(token string) (int, bool) {
+	i, err := strconv.Atoi(token)
+	return i, err == nil && i >= 0
+}
+
+// withIndex returns a copy of p with the token at position pos set to i.
+func withIndex(p Pointer, pos, i int) Pointer {
+	q := p.Append()
+	q[pos] = strconv.Itoa(i)
+	return q
+}
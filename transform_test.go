@@ -0,0 +1,321 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertConverge checks that a then b' and b then a' give the same document.
+func assertConverge(t *testing.T, doc string, a, b, at, bt Patch) []byte {
+	left, err := a.Apply([]byte(doc))
+	require.NoError(t, err)
+	left, err = bt.Apply(left)
+	require.NoError(t, err, "b' %v", bt)
+
+	right, err := b.Apply([]byte(doc))
+	require.NoError(t, err)
+	right, err = at.Apply(right)
+	require.NoError(t, err, "a' %v", at)
+
+	assert.True(t, Equal(left, right), "a then b' gives %s, b then a' gives %s", left, right)
+	return left
+}
+
+func TestTransform(t *testing.T) {
+	testCases := []struct {
+		name     string
+		doc      string
+		a        string
+		b        string
+		at       string
+		bt       string
+		expected string
+	}{
+		{
+			name:     "independent members",
+			doc:      `{"a":1,"b":2}`,
+			a:        `[{"op":"replace","path":"/a","value":3}]`,
+			b:        `[{"op":"remove","path":"/b"}]`,
+			at:       `[{"op":"replace","path":"/a","value":3}]`,
+			bt:       `[{"op":"remove","path":"/b"}]`,
+			expected: `{"a":3}`,
+		},
+		{
+			name:     "inserts at the same index",
+			doc:      `{"l":[1,2]}`,
+			a:        `[{"op":"add","path":"/l/1","value":"a"}]`,
+			b:        `[{"op":"add","path":"/l/1","value":"b"}]`,
+			at:       `[{"op":"add","path":"/l/1","value":"a"}]`,
+			bt:       `[{"op":"add","path":"/l/2","value":"b"}]`,
+			expected: `{"l":[1,"a","b",2]}`,
+		},
+		{
+			name:     "insert before a removal",
+			doc:      `{"l":[1,2,3]}`,
+			a:        `[{"op":"add","path":"/l/0","value":0}]`,
+			b:        `[{"op":"remove","path":"/l/2"}]`,
+			at:       `[{"op":"add","path":"/l/0","value":0}]`,
+			bt:       `[{"op":"remove","path":"/l/3"}]`,
+			expected: `{"l":[0,1,2]}`,
+		},
+		{
+			name:     "removal before a replace",
+			doc:      `{"l":[1,2,3]}`,
+			a:        `[{"op":"remove","path":"/l/0"}]`,
+			b:        `[{"op":"replace","path":"/l/2","value":4}]`,
+			at:       `[{"op":"remove","path":"/l/0"}]`,
+			bt:       `[{"op":"replace","path":"/l/1","value":4}]`,
+			expected: `{"l":[2,4]}`,
+		},
+		{
+			name:     "identical removals",
+			doc:      `{"l":[1,2,3]}`,
+			a:        `[{"op":"remove","path":"/l/1"}]`,
+			b:        `[{"op":"remove","path":"/l/1"},{"op":"add","path":"/l/-","value":4}]`,
+			at:       `[]`,
+			bt:       `[{"op":"add","path":"/l/-","value":4}]`,
+			expected: `{"l":[1,3,4]}`,
+		},
+		{
+			name:     "write within a moved value",
+			doc:      `{"a":{"b":1},"c":{}}`,
+			a:        `[{"op":"move","from":"/a","path":"/c/d"}]`,
+			b:        `[{"op":"add","path":"/a/e","value":2}]`,
+			at:       `[{"op":"move","from":"/a","path":"/c/d"}]`,
+			bt:       `[{"op":"add","path":"/c/d/e","value":2}]`,
+			expected: `{"c":{"d":{"b":1,"e":2}}}`,
+		},
+		{
+			name:     "move within an array",
+			doc:      `{"l":["p","q","r","s"]}`,
+			a:        `[{"op":"move","from":"/l/0","path":"/l/2"}]`,
+			b:        `[{"op":"remove","path":"/l/2"}]`,
+			at:       `[{"op":"move","from":"/l/0","path":"/l/1"}]`,
+			bt:       `[{"op":"remove","path":"/l/1"}]`,
+			expected: `{"l":["q","p","s"]}`,
+		},
+		{
+			name:     "copy into a shifted array",
+			doc:      `{"a":1,"l":[1,2]}`,
+			a:        `[{"op":"copy","from":"/a","path":"/l/1"}]`,
+			b:        `[{"op":"add","path":"/l/0","value":0},{"op":"replace","path":"/l/2","value":3}]`,
+			at:       `[{"op":"copy","from":"/a","path":"/l/2"}]`,
+			bt:       `[{"op":"add","path":"/l/0","value":0},{"op":"replace","path":"/l/3","value":3}]`,
+			expected: `{"a":1,"l":[0,1,1,3]}`,
+		},
+		{
+			name:     "test of a shifted element",
+			doc:      `{"l":[1,2]}`,
+			a:        `[{"op":"test","path":"/l/1","value":2}]`,
+			b:        `[{"op":"add","path":"/l/0","value":0}]`,
+			at:       `[{"op":"test","path":"/l/2","value":2}]`,
+			bt:       `[{"op":"add","path":"/l/0","value":0}]`,
+			expected: `{"l":[0,1,2]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patches := decodePatches(t, tc.a, tc.b)
+			at, bt, err := Transform(patches[0], patches[1])
+			require.NoError(t, err)
+
+			assertPatch(t, tc.at, at)
+			assertPatch(t, tc.bt, bt)
+
+			out := assertConverge(t, tc.doc, patches[0], patches[1], at, bt)
+			assert.True(t, Equal([]byte(tc.expected), out), "expected %s got %s", tc.expected, out)
+		})
+	}
+}
+
+func assertPatch(t *testing.T, expected string, patch Patch) {
+	out, err := json.Marshal(patch)
+	require.NoError(t, err)
+	assert.True(t, Equal([]byte(expected), out), "expected %s got %s", expected, out)
+}
+
+func TestTransformConflicts(t *testing.T) {
+	testCases := []struct {
+		name string
+		a    string
+		b    string
+		path string
+	}{
+		{"replaces", `[{"op":"replace","path":"/a","value":1}]`, `[{"op":"replace","path":"/a","value":2}]`, "/a"},
+		{"adds", `[{"op":"add","path":"/a","value":1}]`, `[{"op":"add","path":"/a","value":2}]`, "/a"},
+		{"remove and write within", `[{"op":"remove","path":"/a"}]`, `[{"op":"add","path":"/a/b","value":2}]`, "/a/b"},
+		{"replace and remove element", `[{"op":"replace","path":"/l/1","value":1}]`, `[{"op":"remove","path":"/l/1"}]`, "/l/1"},
+		{"appends", `[{"op":"add","path":"/l/-","value":1}]`, `[{"op":"add","path":"/l/-","value":2}]`, "/l/-"},
+		{"test", `[{"op":"test","path":"/a","value":{}}]`, `[{"op":"add","path":"/a/b","value":2}]`, "/a"},
+		{"copy", `[{"op":"add","path":"/a/b","value":2}]`, `[{"op":"copy","from":"/a","path":"/c"}]`, "/c"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patches := decodePatches(t, tc.a, tc.b)
+			_, _, err := Transform(patches[0], patches[1])
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrConflict))
+
+			var cerr *ConflictError
+			require.True(t, errors.As(err, &cerr))
+			assert.Equal(t, 0, cerr.A)
+			assert.Equal(t, 0, cerr.B)
+			assert.Equal(t, tc.path, cerr.Path)
+
+			// Conflicts are reported whatever the order of the patches.
+			_, _, err = Transform(patches[1], patches[0])
+			assert.True(t, errors.Is(err, ErrConflict))
+		})
+	}
+}
+
+func TestTransformInvalid(t *testing.T) {
+	patches := decodePatches(t, `[{"op":"remove","path":"/l/-1"}]`, `[]`)
+	_, _, err := Transform(patches[0], patches[1])
+	var perr *PatchError
+	require.True(t, errors.As(err, &perr))
+	assert.True(t, errors.Is(err, ErrInvalidIndex))
+
+	_, _, err = Transform(patches[1], Patch{{Op: "bogus", Path: "/a"}})
+	assert.True(t, errors.Is(err, ErrUnknownOp))
+}
+
+// indexedArrays reports whether the members of the objects of doc cannot be
+// taken for array indices, as assumed by Transform.
+func indexedArrays(doc []byte) bool {
+	var v interface{}
+	_ = json.Unmarshal(doc, &v)
+
+	var walk func(v interface{}) bool
+	walk = func(v interface{}) bool {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				if isIndexToken(k) || !walk(e) {
+					return false
+				}
+			}
+		case []interface{}:
+			for _, e := range v {
+				if !walk(e) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	return walk(v)
+}
+
+// randomOps returns a random patch of n operations applying to doc.
+func randomOps(r *rand.Rand, doc []byte, n int) Patch {
+	patch := Patch{}
+
+	for len(patch) < n {
+		var v interface{}
+		_ = json.Unmarshal(doc, &v)
+
+		var values, gaps []Pointer
+		var walk func(p Pointer, v interface{})
+		walk = func(p Pointer, v interface{}) {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				keys := make([]string, 0, len(v))
+				for k := range v {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					values = append(values, p.Append(k))
+					gaps = append(gaps, p.Append(k))
+					walk(p.Append(k), v[k])
+				}
+				gaps = append(gaps, p.Append(fmt.Sprint("k", r.Intn(3))))
+			case []interface{}:
+				for i, e := range v {
+					values = append(values, p.Append(fmt.Sprint(i)))
+					gaps = append(gaps, p.Append(fmt.Sprint(i)))
+					walk(p.Append(fmt.Sprint(i)), e)
+				}
+				gaps = append(gaps, p.Append(fmt.Sprint(len(v))), p.Append("-"))
+			}
+		}
+		walk(Pointer{}, v)
+		if len(values) == 0 {
+			return patch
+		}
+
+		value := func() interface{} {
+			switch r.Intn(3) {
+			case 0:
+				return r.Intn(10)
+			case 1:
+				return []interface{}{r.Intn(10)}
+			}
+			return map[string]interface{}{"k0": r.Intn(10)}
+		}
+
+		op := map[string]interface{}{}
+		switch r.Intn(6) {
+		case 0, 1:
+			op["op"], op["path"], op["value"] = "add", gaps[r.Intn(len(gaps))].String(), value()
+		case 2:
+			op["op"], op["path"] = "remove", values[r.Intn(len(values))].String()
+		case 3:
+			op["op"], op["path"], op["value"] = "replace", values[r.Intn(len(values))].String(), value()
+		case 4:
+			op["op"], op["from"], op["path"] = "move", values[r.Intn(len(values))].String(), gaps[r.Intn(len(gaps))].String()
+		default:
+			op["op"], op["from"], op["path"] = "copy", values[r.Intn(len(values))].String(), gaps[r.Intn(len(gaps))].String()
+		}
+
+		raw, _ := json.Marshal([]interface{}{op})
+		p, err := DecodePatch(raw)
+		if err != nil {
+			continue
+		}
+		out, err := p.Apply(doc)
+		if err != nil || !indexedArrays(out) {
+			continue
+		}
+
+		patch = append(patch, p...)
+		doc = out
+	}
+
+	return patch
+}
+
+func TestTransformRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	docs := []string{
+		`{"a":[1,[2,3],{"k0":4}],"b":{"k1":[5,6],"k2":7},"c":[8,9,10]}`,
+		`[[1,2,[3,4]],[5],{"k0":[6,7]},8]`,
+	}
+
+	for _, doc := range docs {
+		converged := 0
+		for i := 0; i < 1000; i++ {
+			a, b := randomOps(r, []byte(doc), 1+r.Intn(3)), randomOps(r, []byte(doc), 1+r.Intn(3))
+			at, bt, err := Transform(a, b)
+			if errors.Is(err, ErrConflict) {
+				continue
+			}
+			require.NoError(t, err)
+			converged++
+			assertConverge(t, doc, a, b, at, bt)
+		}
+
+		assert.True(t, converged > 300, "%d patches converged", converged)
+	}
+}